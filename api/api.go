@@ -0,0 +1,54 @@
+// Package api holds what the server and client packages share: errors, request IDs and how resources and their URLs
+// are named. Unlike the server package, importing it has no side effects, so clients don't register any routes.
+package api
+
+import (
+	"context"
+	"regexp"
+)
+
+type RequestError struct {
+	Error   error
+	Message string
+	Code    int
+	// Violations lists every problem found with the request, when there may be several
+	Violations []Violation
+}
+
+// Violation is a way in which a request doesn't meet the requirements of a resource
+type Violation struct {
+	// Field is the path to the value in its JSON representation e.g. author.name or tags[1], or "" for the whole value
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// RequestIDHeader is read to find the caller's request ID, and echoed in every response
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request being handled, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a copy of ctx carrying a request ID, which clients pass on in the RequestIDHeader
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// TODO Support more characters
+var patternRegex = regexp.MustCompile("/\\{([a-z_]+)\\}")
+
+// PatternParameters lists the parameters of a pattern e.g. "/{title}", in the order they appear in URLs
+func PatternParameters(pattern string) []string {
+	parameterElements := patternRegex.FindAllStringSubmatch(pattern, -1)
+
+	parameters := make([]string, len(parameterElements))
+	for idx, element := range parameterElements {
+		parameters[idx] = element[1]
+	}
+
+	return parameters
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ResourceNamer is implemented by types which choose the name they're registered under, rather than having one
+// derived from the name of the type
+type ResourceNamer interface {
+	ResourceName() string
+}
+
+// NamingPolicy derives the name of a resource, which is the first element of its URLs, from the name of its type
+type NamingPolicy func(typeName string) string
+
+var (
+	// TypeName uses the name of the type as it is e.g. BookReview. This is the default.
+	TypeName NamingPolicy = func(typeName string) string { return typeName }
+	// LowerCase uses the name of the type in lower case e.g. bookreview
+	LowerCase NamingPolicy = strings.ToLower
+	// KebabCase separates the words in the name of the type with hyphens e.g. book-review
+	KebabCase NamingPolicy = kebabCase
+)
+
+// Plural uses the plural of the name given by another policy e.g. Plural(KebabCase) gives book-reviews
+func Plural(policy NamingPolicy) NamingPolicy {
+	return func(typeName string) string {
+		return plural(policy(typeName))
+	}
+}
+
+var namingMutex sync.RWMutex
+var namingPolicy = TypeName
+
+// SetNamingPolicy sets how resources registered after it's called are named, and the names clients use for them,
+// unless their types implement ResourceNamer or they're registered with an explicit name
+func SetNamingPolicy(policy NamingPolicy) {
+	namingMutex.Lock()
+	defer namingMutex.Unlock()
+
+	namingPolicy = policy
+}
+
+func getNamingPolicy() NamingPolicy {
+	namingMutex.RLock()
+	defer namingMutex.RUnlock()
+
+	return namingPolicy
+}
+
+// ResourceName returns the name a resource of the given type is registered under, unless it's registered with an
+// explicit name. Types without a name, such as anonymous structs, give "".
+func ResourceName(i interface{}) string {
+	t := reflect.TypeOf(i)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if namer, ok := reflect.New(t).Interface().(ResourceNamer); ok {
+		return namer.ResourceName()
+	}
+	if t.Name() == "" {
+		return ""
+	}
+	return getNamingPolicy()(t.Name())
+}
+
+func kebabCase(typeName string) string {
+	runes := []rune(typeName)
+	var b strings.Builder
+	for idx, r := range runes {
+		if idx > 0 && unicode.IsUpper(r) {
+			previous := runes[idx-1]
+			// An upper case letter starts a word, unless it's part of an acronym e.g. the S in HTTPServer
+			endsAcronym := unicode.IsUpper(previous) && idx+1 < len(runes) && unicode.IsLower(runes[idx+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || endsAcronym {
+				b.WriteRune('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func plural(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case name == "":
+		return ""
+	case strings.HasSuffix(lower, "s") || strings.HasSuffix(lower, "x") || strings.HasSuffix(lower, "z") ||
+		strings.HasSuffix(lower, "ch") || strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
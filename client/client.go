@@ -0,0 +1,234 @@
+// Package client calls the resources of a gowest server, decoding their representations into the Go types they were
+// registered with. Given the registration
+//
+//	server.Resource(book{}, "/{title}", getBookHandler)
+//
+// or its generic equivalent server.Register[book]("/{title}", getBook), a client for it is
+//
+//	books := client.NewResource[book](client.New("http://localhost:8080"), "/{title}")
+//	b, err := books.Get(ctx, "Neuromancer")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cleggatt/gowest/api"
+)
+
+// Decoder reads a representation into v, which is a pointer to the resource's type or to a slice of it
+type Decoder func(r io.Reader, v interface{}) error
+
+func jsonDecoder(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+var decoderMutex sync.RWMutex
+var decoders = map[string]Decoder{
+	"json": jsonDecoder,
+	// HAL adds _links and _embedded members, which encoding/json ignores
+	"hal": jsonDecoder}
+
+// RegisterDecoder makes format available to clients, using the given decoder to read its representations
+func RegisterDecoder(format string, decoder Decoder) {
+	decoderMutex.Lock()
+	defer decoderMutex.Unlock()
+
+	decoders[format] = decoder
+}
+
+func getDecoder(format string) (Decoder, bool) {
+	decoderMutex.RLock()
+	defer decoderMutex.RUnlock()
+
+	decoder, ok := decoders[format]
+	return decoder, ok
+}
+
+// Client calls a gowest server at a base URL, which includes the server's mount prefix, if it has one
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	format     string
+}
+
+// Option configures optional behaviour of a client
+type Option func(c *Client)
+
+// WithHTTPClient sends requests using the given client, rather than http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithFormat requests representations in the given format, rather than JSON. The format must have a decoder.
+func WithFormat(format string) Option {
+	return func(c *Client) {
+		c.format = format
+	}
+}
+
+// New returns a client for the server at baseURL e.g. http://localhost:8080/api
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), httpClient: http.DefaultClient, format: "json"}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Resource calls a resource registered with a type of T
+type Resource[T any] struct {
+	client     *Client
+	name       string
+	parameters []string
+}
+
+// NewResource returns a client for the resource registered with type T and pattern e.g. "/{title}", which must be the
+// pattern the server registered it with. The resource is named as the server names it, so a client using a naming
+// policy other than the default must set the same one with api.SetNamingPolicy.
+func NewResource[T any](c *Client, pattern string) *Resource[T] {
+	return &Resource[T]{client: c, name: api.ResourceName(new(T)), parameters: api.PatternParameters(pattern)}
+}
+
+// NewNamedResource returns a client for a resource registered with type T under an explicit name, with the Named option
+func NewNamedResource[T any](c *Client, name string, pattern string) *Resource[T] {
+	return &Resource[T]{client: c, name: name, parameters: api.PatternParameters(pattern)}
+}
+
+// resourceURL builds the URL of the resource from the values of its parameters, in the order they appear in the pattern
+func (r *Resource[T]) resourceURL(query url.Values, values []string) (string, *api.RequestError) {
+	if len(values) != len(r.parameters) {
+		err := fmt.Errorf("[%s] has parameters %v, but %d values were given", r.name, r.parameters, len(values))
+		return "", &api.RequestError{Error: err, Message: err.Error(), Code: http.StatusBadRequest}
+	}
+	path := r.client.baseURL + "/" + url.PathEscape(r.name)
+	for _, value := range values {
+		path += "/" + url.PathEscape(value)
+	}
+	q := url.Values{}
+	for name, v := range query {
+		q[name] = v
+	}
+	q.Set("fmt", r.client.format)
+	return path + "?" + q.Encode(), nil
+}
+
+// do sends a request, decoding the response into v unless it's nil or the response is empty
+func (r *Resource[T]) do(ctx context.Context, method string, target string, body io.Reader, v interface{}) *api.RequestError {
+	decoder, ok := getDecoder(r.client.format)
+	if !ok {
+		err := fmt.Errorf("No decoder registered for [%s]", r.client.format)
+		return &api.RequestError{Error: err, Message: err.Error(), Code: http.StatusNotAcceptable}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return &api.RequestError{Error: err, Message: err.Error(), Code: http.StatusBadRequest}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id := api.RequestID(ctx); id != "" {
+		req.Header.Set(api.RequestIDHeader, id)
+	}
+
+	resp, err := r.client.httpClient.Do(req)
+	if err != nil {
+		return &api.RequestError{Error: err, Message: "Unable to reach the server", Code: http.StatusBadGateway}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseError(req, resp)
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := decoder(resp.Body, v); err != nil {
+		return &api.RequestError{Error: fmt.Errorf("Unable to decode response from %s %s: %v", method, target, err),
+			Message: "The response is invalid", Code: http.StatusBadGateway}
+	}
+	return nil
+}
+
+// maxErrorBody limits how much of an error response is read
+const maxErrorBody = 64 * 1024
+
+// responseError maps an error response back to the RequestError the server returned, from its JSON representation or
+// the plain text fallback
+func responseError(req *http.Request, resp *http.Response) *api.RequestError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	e := &api.RequestError{Code: resp.StatusCode, Message: strings.SplitN(strings.TrimSpace(string(body)), "\n", 2)[0]}
+
+	var representation struct {
+		Message    string          `json:"message"`
+		Violations []api.Violation `json:"violations"`
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/json" && json.Unmarshal(body, &representation) == nil {
+		e.Message, e.Violations = representation.Message, representation.Violations
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	e.Error = fmt.Errorf("%s %s returned [%d] [%s] with request ID [%s]", req.Method, req.URL, resp.StatusCode, e.Message,
+		resp.Header.Get(api.RequestIDHeader))
+	return e
+}
+
+// Get fetches the resource identified by the values of its parameters
+func (r *Resource[T]) Get(ctx context.Context, values ...string) (T, *api.RequestError) {
+	var resource T
+	target, err := r.resourceURL(nil, values)
+	if err != nil {
+		return resource, err
+	}
+	err = r.do(ctx, http.MethodGet, target, nil, &resource)
+	return resource, err
+}
+
+// List fetches a resource whose handler returns a collection of T. The query may sort, filter or page the collection
+// e.g. url.Values{"sort": {"-title"}, "page": {"2"}}.
+func (r *Resource[T]) List(ctx context.Context, query url.Values, values ...string) ([]T, *api.RequestError) {
+	var resources []T
+	target, err := r.resourceURL(query, values)
+	if err != nil {
+		return nil, err
+	}
+	err = r.do(ctx, http.MethodGet, target, nil, &resources)
+	return resources, err
+}
+
+// Put replaces the resource identified by the values of its parameters. It returns the resource as stored, or the zero
+// value of T if the server doesn't return it.
+func (r *Resource[T]) Put(ctx context.Context, resource T, values ...string) (T, *api.RequestError) {
+	var stored T
+	target, err := r.resourceURL(nil, values)
+	if err != nil {
+		return stored, err
+	}
+	b, jsonErr := json.Marshal(resource)
+	if jsonErr != nil {
+		return stored, &api.RequestError{Error: jsonErr, Message: "Unable to encode the resource", Code: http.StatusBadRequest}
+	}
+	err = r.do(ctx, http.MethodPut, target, bytes.NewReader(b), &stored)
+	return stored, err
+}
+
+// Delete removes the resource identified by the values of its parameters
+func (r *Resource[T]) Delete(ctx context.Context, values ...string) *api.RequestError {
+	target, err := r.resourceURL(nil, values)
+	if err != nil {
+		return err
+	}
+	return r.do(ctx, http.MethodDelete, target, nil, nil)
+}
@@ -0,0 +1,21 @@
+package client_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+type book struct {
+	Title  string `json:"title,omitempty" validate:"required"`
+	Author string `json:"author"`
+}
+
+func TestClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	log.SetOutput(ioutil.Discard)
+	RunSpecs(t, "Client Suite")
+}
@@ -0,0 +1,118 @@
+package client_test
+
+import (
+	. "github.com/cleggatt/gowest/client"
+	"github.com/cleggatt/gowest/internal/storetest"
+	"github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+var _ = Describe("client.go", func() {
+	var ts *httptest.Server
+	var books *Resource[book]
+	var shelf storetest.Store[book]
+	ctx := context.Background()
+	BeforeEach(func() {
+		shelf = storetest.Store[book]{"Neuromancer": {"Neuromancer", "Gibson, William"}}
+		server.Resource(book{}, "/{title}", shelf.Get, server.Put(shelf.Put), server.Delete(shelf.Delete))
+		ts = httptest.NewServer(http.HandlerFunc(server.MainHandler))
+		books = NewResource[book](New(ts.URL), "/{title}")
+	})
+	AfterEach(func() {
+		ts.Close()
+		server.ClearHandlers()
+	})
+	Describe("getting a resource", func() {
+		It("should decode the response into the resource's type", func() {
+			// Exercise
+			b, err := books.Get(ctx, "Neuromancer")
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(b).To(Equal(book{"Neuromancer", "Gibson, William"}))
+		})
+		It("should map an error response back to a RequestError", func() {
+			// Exercise
+			_, err := books.Get(ctx, "Idoru")
+			// Verify
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(http.StatusNotFound))
+			Expect(err.Message).To(Equal("Not found"))
+		})
+		It("should map a plain text error response back to a RequestError", func() {
+			// Setup
+			RegisterDecoder("text", func(_ io.Reader, _ interface{}) error { return nil })
+			// Exercise
+			_, err := NewResource[book](New(ts.URL, WithFormat("text")), "/{title}").Get(ctx, "Idoru")
+			// Verify
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(http.StatusNotFound))
+			Expect(err.Message).To(Equal("Not found"))
+		})
+		It("should request the chosen format", func() {
+			// Exercise
+			b, err := NewResource[book](New(ts.URL, WithFormat("hal")), "/{title}").Get(ctx, "Neuromancer")
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(b.Author).To(Equal("Gibson, William"))
+		})
+		It("should reject a format without a decoder", func() {
+			// Exercise
+			_, err := NewResource[book](New(ts.URL, WithFormat("csv")), "/{title}").Get(ctx, "Neuromancer")
+			// Verify
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(http.StatusNotAcceptable))
+		})
+		It("should reject the wrong number of parameters", func() {
+			// Exercise
+			_, err := books.Get(ctx)
+			// Verify
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+	Describe("listing a collection", func() {
+		It("should decode each element and pass the query on", func() {
+			// Setup
+			server.SingletonResource(book{}, func(_ server.PathParameters) (interface{}, *server.RequestError) {
+				return []book{{"Count Zero", "Gibson, William"}, {"Neuromancer", "Gibson, William"}}, nil
+			}, server.Sortable("title"))
+			// Exercise
+			list, err := NewResource[book](New(ts.URL), "").List(ctx, url.Values{"sort": {"-title"}})
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(list).To(Equal([]book{{"Neuromancer", "Gibson, William"}, {"Count Zero", "Gibson, William"}}))
+		})
+	})
+	Describe("modifying a resource", func() {
+		It("should PUT the encoded resource", func() {
+			// Exercise
+			stored, err := books.Put(ctx, book{"Count Zero", "Gibson, William"}, "Count Zero")
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(stored).To(Equal(book{"Count Zero", "Gibson, William"}))
+			Expect(shelf["Count Zero"]).To(Equal(stored))
+		})
+		It("should return the violations of an invalid resource", func() {
+			// Exercise
+			_, err := books.Put(ctx, book{Author: "Gibson, William"}, "Count Zero")
+			// Verify
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(err.Violations).To(Equal([]server.Violation{{Field: "title", Message: "is required"}}))
+		})
+		It("should DELETE the resource", func() {
+			// Exercise
+			err := books.Delete(ctx, "Neuromancer")
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(shelf).NotTo(HaveKey("Neuromancer"))
+		})
+	})
+})
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"github.com/cleggatt/gowest/client"
 	. "github.com/cleggatt/gowest/server"
 	"io/ioutil"
 	"log"
@@ -19,7 +21,7 @@ func getBookHandler(params PathParameters) (interface{}, *RequestError) {
 }
 
 func main() {
-	Resource(book{}, "/{title}", getBookHandler)
+	MustResource(book{}, "/{title}", getBookHandler)
 
 	go http.ListenAndServe(":8080", nil)
 
@@ -38,4 +40,13 @@ func main() {
 	if !bytes.Equal(body, expected) {
 		log.Fatalf("Expected [%v], Actual [%v]", string(expected), string(body))
 	}
+
+	books := client.NewResource[book](client.New("http://localhost:8080"), "/{title}")
+	b, reqErr := books.Get(context.Background(), "Neuromancer")
+	if reqErr != nil {
+		log.Fatal(reqErr.Error)
+	}
+	if b != (book{"Neuromancer", "Gibson, William"}) {
+		log.Fatalf("Expected [%v], Actual [%v]", book{"Neuromancer", "Gibson, William"}, b)
+	}
 }
@@ -0,0 +1,35 @@
+// Package storetest provides an in-memory store of resources keyed by title, whose methods can be registered as the
+// handlers of a resource with the pattern "/{title}". It is for the tests of the other packages.
+package storetest
+
+import (
+	"net/http"
+
+	"github.com/cleggatt/gowest/server"
+)
+
+// Store holds resources of type T by their title
+type Store[T any] map[string]T
+
+// Get returns the resource with the requested title, or a 404 error if there isn't one
+func (store Store[T]) Get(params server.PathParameters) (interface{}, *server.RequestError) {
+	title, _ := params.Get("title")
+	if resource, ok := store[title]; ok {
+		return resource, nil
+	}
+	return nil, &server.RequestError{Message: "Not found", Code: http.StatusNotFound}
+}
+
+// Put stores the decoded resource under the requested title and returns it
+func (store Store[T]) Put(params server.PathParameters, resource interface{}) (interface{}, *server.RequestError) {
+	title, _ := params.Get("title")
+	store[title] = resource.(T)
+	return store[title], nil
+}
+
+// Delete removes the resource with the requested title
+func (store Store[T]) Delete(params server.PathParameters) *server.RequestError {
+	title, _ := params.Get("title")
+	delete(store, title)
+	return nil
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachePolicy describes how clients and intermediaries may cache a resource
+type CachePolicy struct {
+	MaxAge  time.Duration
+	Private bool
+	NoStore bool
+}
+
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	directives := []string{"public"}
+	if p.Private {
+		directives[0] = "private"
+	}
+	directives = append(directives, "max-age="+strconv.Itoa(int(p.MaxAge/time.Second)))
+	return strings.Join(directives, ", ")
+}
+
+// CacheControl sets the Cache-Control header sent with the resource's representations
+func CacheControl(policy CachePolicy) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.cachePolicy = &policy
+	}
+}
+
+// ServerCache keeps rendered representations of the resource in memory for ttl, so repeated GETs are served without
+// calling the handler. Use InvalidateCache when the underlying data changes; successful PUTs and DELETEs invalidate
+// the resource automatically.
+func ServerCache(ttl time.Duration) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.serverCacheTTL = ttl
+	}
+}
+
+// Bounds memory use. Once full, we stop caching until entries expire.
+const maxCachedResponses = 1024
+
+type cachedResponse struct {
+	typeName string
+	header   http.Header
+	body     []byte
+	v        validators
+	stored   time.Time
+	expires  time.Time
+}
+
+type responseCache struct {
+	mutex     sync.RWMutex
+	responses map[string]*cachedResponse
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{responses: make(map[string]*cachedResponse)}
+}
+
+var defaultResponseCache = newResponseCache()
+
+func cacheKey(r *http.Request) string {
+	// Encode sorts the parameters, so their order doesn't matter
+	return r.URL.Path + "?" + r.URL.Query().Encode() + "#" + requestFormat(r) + "#" + negotiateEncoding(r)
+}
+
+// isCacheable reports whether the response may be cached. Private responses may be user specific, so aren't shared.
+func isCacheable(r *http.Request, entry mutexEntry) bool {
+	if entry.cachePolicy != nil && (entry.cachePolicy.NoStore || entry.cachePolicy.Private) {
+		return false
+	}
+	return entry.serverCacheTTL > 0 && isSafeMethod(r)
+}
+
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, entry mutexEntry) bool {
+	if !isCacheable(r, entry) {
+		return false
+	}
+	c.mutex.RLock()
+	cached, ok := c.responses[cacheKey(r)]
+	c.mutex.RUnlock()
+	if !ok || time.Now().After(cached.expires) {
+		return false
+	}
+
+	logf(r.Context(), "Serving cached response for [%s]", r.URL.RequestURI())
+	for k, v := range cached.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(cached.stored)/time.Second)))
+	if writeValidators(w, r, cached.v) {
+		return true
+	}
+	if _, err := w.Write(cached.body); err != nil {
+		logf(r.Context(), "Unable to write cached response: %v", err)
+	}
+	return true
+}
+
+type cacheRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *cacheRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *cacheRecorder) Write(p []byte) (int, error) {
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+func (rec *cacheRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// record returns a writer which captures the response, and a function to store it once it has been written
+// successfully
+func (c *responseCache) record(w http.ResponseWriter, r *http.Request, entry mutexEntry) (http.ResponseWriter, func()) {
+	if !isCacheable(r, entry) {
+		return w, func() {}
+	}
+	rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK}
+	return rec, func() {
+		// A 304 has no body to reuse
+		if rec.status != http.StatusOK {
+			return
+		}
+		header := make(http.Header)
+		for _, k := range []string{"Content-Type", "Content-Encoding", "Content-Length", "Cache-Control", "Vary"} {
+			if v, ok := w.Header()[k]; ok {
+				header[k] = v
+			}
+		}
+		now := time.Now()
+		c.store(cacheKey(r), &cachedResponse{entry.typeName, header, rec.body.Bytes(), headerValidators(w.Header()), now, now.Add(entry.serverCacheTTL)})
+	}
+}
+
+func (c *responseCache) store(key string, cached *cachedResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.responses) >= maxCachedResponses {
+		now := time.Now()
+		for k, v := range c.responses {
+			if now.After(v.expires) {
+				delete(c.responses, k)
+			}
+		}
+		if len(c.responses) >= maxCachedResponses {
+			return
+		}
+	}
+	c.responses[key] = cached
+}
+
+func (c *responseCache) invalidate(typeName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for k, v := range c.responses {
+		if v.typeName == typeName {
+			delete(c.responses, k)
+		}
+	}
+}
+
+// InvalidateCache discards all cached representations of the given resource type
+func InvalidateCache(i interface{}) {
+	t, name := getInterfaceTypeName(i)
+	if entry := defaultHandlerMutex.entryFor(i); entry.handler != nil {
+		name = entry.typeName
+	}
+	log.Printf("Invalidating cached responses for [%s] as [%s]\n", t.String(), name)
+	defaultResponseCache.invalidate(name)
+}
+
+func setCachePolicy(w http.ResponseWriter, entry mutexEntry) {
+	if entry.cachePolicy != nil {
+		w.Header().Set("Cache-Control", entry.cachePolicy.String())
+	}
+}
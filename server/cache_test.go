@@ -0,0 +1,149 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func createCountingHandler(count *int) GetHandler {
+	return func(_ PathParameters) (interface{}, *RequestError) {
+		*count++
+		return book{"Neuromancer", "Gibson, William"}, nil
+	}
+}
+
+func get(rawurl string) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	MainHandler(resp, request(rawurl))
+	return resp
+}
+
+var _ = Describe("cache.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("setting a cache policy", func() {
+		cases := map[string]CachePolicy{
+			"public, max-age=60":  CachePolicy{MaxAge: time.Minute},
+			"private, max-age=10": CachePolicy{MaxAge: 10 * time.Second, Private: true},
+			"no-store":            CachePolicy{MaxAge: time.Minute, NoStore: true}}
+		for k, v := range cases {
+			expected, policy := k, v
+			It("should send "+expected, func() {
+				// Setup
+				SingletonResource(book{}, getSingleResourceHandler, CacheControl(policy))
+				// Exercise
+				resp := get("http://localhost:8080/book?fmt=json")
+				// Verify
+				Expect(resp.Header().Get("Cache-Control")).To(Equal(expected))
+			})
+		}
+		It("should not be sent with errors", func() {
+			// Setup
+			SingletonResource(book{}, errorResourceHandler, CacheControl(CachePolicy{MaxAge: time.Minute}))
+			// Exercise
+			resp := get("http://localhost:8080/book?fmt=json")
+			// Verify
+			Expect(resp.Header().Get("Cache-Control")).To(Equal("no-store"))
+		})
+		It("should not be sent with errors found while rendering", func() {
+			// Setup
+			SingletonResource(book{}, getSingleResourceHandler, CacheControl(CachePolicy{MaxAge: time.Hour}))
+			// Exercise
+			unsupported := get("http://localhost:8080/book?fmt=bogus")
+			unknownField := get("http://localhost:8080/book?fmt=json&fields=nope")
+			// Verify
+			Expect(unsupported.Code).To(Equal(http.StatusNotAcceptable))
+			Expect(unsupported.Header().Get("Cache-Control")).To(Equal("no-store"))
+			Expect(unknownField.Code).To(Equal(http.StatusBadRequest))
+			Expect(unknownField.Header().Get("Cache-Control")).To(Equal("no-store"))
+		})
+	})
+	Describe("caching responses on the server", func() {
+		It("should serve repeated requests without calling the handler", func() {
+			// Setup
+			count := 0
+			SingletonResource(book{}, createCountingHandler(&count), ServerCache(time.Minute))
+			// Exercise
+			first := get("http://localhost:8080/book?fmt=json")
+			second := get("http://localhost:8080/book?fmt=json")
+			// Verify
+			Expect(count).To(Equal(1))
+			Expect(second.Body.String()).To(Equal(first.Body.String()))
+			Expect(second.Header().Get("ETag")).To(Equal(first.Header().Get("ETag")))
+			Expect(second.Header().Get("Age")).To(Equal("0"))
+		})
+		It("should cache each format separately", func() {
+			// Setup
+			count := 0
+			SingletonResource(book{}, createCountingHandler(&count), ServerCache(time.Minute))
+			// Exercise
+			get("http://localhost:8080/book?fmt=json")
+			resp := get("http://localhost:8080/book?fmt=text")
+			// Verify
+			Expect(count).To(Equal(2))
+			Expect(resp.Body.String()).To(Equal("Neuromancer by Gibson, William"))
+		})
+		It("should honour conditional requests", func() {
+			// Setup
+			count := 0
+			SingletonResource(book{}, createCountingHandler(&count), ServerCache(time.Minute))
+			etag := get("http://localhost:8080/book?fmt=json").Header().Get("ETag")
+			req := request("http://localhost:8080/book?fmt=json")
+			req.Header = http.Header{"If-None-Match": {etag}}
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, req)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+			Expect(count).To(Equal(1))
+		})
+		It("should expire responses", func() {
+			// Setup
+			count := 0
+			SingletonResource(book{}, createCountingHandler(&count), ServerCache(time.Millisecond))
+			// Exercise
+			get("http://localhost:8080/book?fmt=json")
+			time.Sleep(5 * time.Millisecond)
+			get("http://localhost:8080/book?fmt=json")
+			// Verify
+			Expect(count).To(Equal(2))
+		})
+		It("should not cache errors", func() {
+			// Setup
+			count := 0
+			SingletonResource(book{}, createCountingHandler(&count), ServerCache(time.Minute))
+			// Exercise
+			get("http://localhost:8080/book?fmt=missing")
+			get("http://localhost:8080/book?fmt=missing")
+			// Verify
+			Expect(count).To(Equal(2))
+		})
+		It("should not cache private responses", func() {
+			// Setup
+			count := 0
+			SingletonResource(book{}, createCountingHandler(&count), ServerCache(time.Minute), CacheControl(CachePolicy{Private: true}))
+			// Exercise
+			get("http://localhost:8080/book?fmt=json")
+			get("http://localhost:8080/book?fmt=json")
+			// Verify
+			Expect(count).To(Equal(2))
+		})
+		It("should be invalidated by type", func() {
+			// Setup
+			count := 0
+			SingletonResource(book{}, createCountingHandler(&count), ServerCache(time.Minute))
+			get("http://localhost:8080/book?fmt=json")
+			// Exercise
+			InvalidateCache(book{})
+			get("http://localhost:8080/book?fmt=json")
+			// Verify
+			Expect(count).To(Equal(2))
+		})
+	})
+})
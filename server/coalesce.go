@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Coalesce shares a single handler call and rendering between concurrent identical GETs for the resource. Handlers
+// should only opt in if their result doesn't depend on anything but the URL.
+func Coalesce() ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.coalesce = true
+	}
+}
+
+// bufferedResponse collects a response so that it can be written to several clients
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+type coalescedCall struct {
+	done     sync.WaitGroup
+	response *bufferedResponse
+	err      *RequestError
+}
+
+type coalescer struct {
+	mutex sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+var defaultCoalescer = &coalescer{calls: make(map[string]*coalescedCall)}
+
+// do calls fn, unless a call with the same key is already in progress, in which case it waits for and returns that
+// call's result
+func (c *coalescer) do(key string, fn func() (*bufferedResponse, *RequestError)) (*bufferedResponse, *RequestError, bool) {
+	c.mutex.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mutex.Unlock()
+		call.done.Wait()
+		return call.response, call.err, true
+	}
+	call := new(coalescedCall)
+	call.done.Add(1)
+	c.calls[key] = call
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.calls, key)
+		c.mutex.Unlock()
+		call.done.Done()
+	}()
+	call.response, call.err = fn()
+	return call.response, call.err, false
+}
+
+// unconditional returns a copy of the request that will render a complete response for sharing. It is detached from
+// the client's cancellation, since other clients are relying on it.
+func unconditional(r *http.Request) *http.Request {
+	shared := r.Clone(context.WithoutCancel(r.Context()))
+	shared.Header.Del("If-None-Match")
+	shared.Header.Del("If-Modified-Since")
+	return shared
+}
+
+func serveCoalesced(w http.ResponseWriter, r *http.Request, entry mutexEntry, timing *requestTiming) *RequestError {
+	start := time.Now()
+	response, err, shared := defaultCoalescer.do(entry.typeName+" "+cacheKey(r), func() (*bufferedResponse, *RequestError) {
+		response := newBufferedResponse()
+		err := serveResource(response, unconditional(r), entry, timing)
+		return response, err
+	})
+	if shared {
+		logf(r.Context(), "Shared response for [%s]", r.URL.RequestURI())
+		timing.handler = time.Since(start)
+	}
+	if err != nil {
+		return err
+	}
+
+	for k, v := range response.header {
+		w.Header()[k] = v
+	}
+	if writeValidators(w, r, headerValidators(response.header)) {
+		return nil
+	}
+	if response.status != http.StatusOK {
+		w.WriteHeader(response.status)
+	}
+	if _, err := w.Write(response.body.Bytes()); err != nil {
+		logf(r.Context(), "Unable to write shared response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func createBlockingHandler(count *int32, entered chan bool, release chan bool) GetHandler {
+	return func(_ PathParameters) (interface{}, *RequestError) {
+		atomic.AddInt32(count, 1)
+		entered <- true
+		<-release
+		return book{"Neuromancer", "Gibson, William"}, nil
+	}
+}
+
+func concurrentGets(requests []*http.Request) []*httptest.ResponseRecorder {
+	responses := make([]*httptest.ResponseRecorder, len(requests))
+	var wg sync.WaitGroup
+	for idx, req := range requests {
+		wg.Add(1)
+		responses[idx] = httptest.NewRecorder()
+		go func(resp *httptest.ResponseRecorder, req *http.Request) {
+			defer wg.Done()
+			MainHandler(resp, req)
+		}(responses[idx], req)
+	}
+	wg.Wait()
+	return responses
+}
+
+var _ = Describe("coalesce.go", func() {
+	var count int32
+	var entered, release chan bool
+	BeforeEach(func() {
+		count = 0
+		entered, release = make(chan bool, 10), make(chan bool)
+	})
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	// Starts a request which blocks in the handler, then releases it once the others have had time to join it
+	releaseAfterFirst := func() {
+		go func() {
+			<-entered
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+		}()
+	}
+	Describe("handling concurrent identical requests", func() {
+		It("should call the handler once", func() {
+			// Setup
+			SingletonResource(book{}, createBlockingHandler(&count, entered, release), Coalesce())
+			releaseAfterFirst()
+			// Exercise
+			responses := concurrentGets([]*http.Request{
+				request("http://localhost:8080/book?fmt=json"),
+				request("http://localhost:8080/book?fmt=json"),
+				request("http://localhost:8080/book?fmt=json")})
+			// Verify
+			Expect(atomic.LoadInt32(&count)).To(Equal(int32(1)))
+			for _, resp := range responses {
+				Expect(resp.Code).To(Equal(http.StatusOK))
+				Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+				Expect(resp.Header().Get("ETag")).ToNot(Equal(""))
+			}
+		})
+		It("should apply each request's conditions", func() {
+			// Setup
+			SingletonResource(book{}, createBlockingHandler(&count, entered, release), Coalesce())
+			releaseAfterFirst()
+			rendered := httptest.NewRecorder()
+			MarshallResponse(book{"Neuromancer", "Gibson, William"}, rendered, request("http://localhost:8080/book?fmt=json"))
+			etag := rendered.Header().Get("ETag")
+			conditional := request("http://localhost:8080/book?fmt=json")
+			conditional.Header = http.Header{"If-None-Match": {etag}}
+			// Exercise
+			responses := concurrentGets([]*http.Request{request("http://localhost:8080/book?fmt=json"), conditional})
+			// Verify
+			Expect(responses[0].Code).To(Equal(http.StatusOK))
+			Expect(responses[1].Code).To(Equal(http.StatusNotModified))
+		})
+		It("should not share between formats", func() {
+			// Setup
+			SingletonResource(book{}, createBlockingHandler(&count, entered, release), Coalesce())
+			close(release)
+			// Exercise
+			concurrentGets([]*http.Request{
+				request("http://localhost:8080/book?fmt=json"),
+				request("http://localhost:8080/book?fmt=text")})
+			// Verify
+			Expect(atomic.LoadInt32(&count)).To(Equal(int32(2)))
+		})
+	})
+	Describe("handling concurrent requests without coalescing", func() {
+		It("should call the handler for each", func() {
+			// Setup
+			SingletonResource(book{}, createBlockingHandler(&count, entered, release))
+			close(release)
+			// Exercise
+			concurrentGets([]*http.Request{
+				request("http://localhost:8080/book?fmt=json"),
+				request("http://localhost:8080/book?fmt=json")})
+			// Verify
+			Expect(atomic.LoadInt32(&count)).To(Equal(int32(2)))
+		})
+	})
+})
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var compressionMutex sync.RWMutex
+
+// Compressing small responses costs more than it saves
+var compressionThreshold = 1024
+
+// SetCompressionThreshold sets the size in bytes below which responses are not compressed. A negative threshold
+// disables compression.
+func SetCompressionThreshold(n int) {
+	compressionMutex.Lock()
+	defer compressionMutex.Unlock()
+
+	compressionThreshold = n
+}
+
+func getCompressionThreshold() int {
+	compressionMutex.RLock()
+	defer compressionMutex.RUnlock()
+
+	return compressionThreshold
+}
+
+// Supported encodings, in order of preference when the client has no preference
+var compressionEncodings = []string{"gzip", "deflate"}
+
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson", "application/json-seq":
+		return true
+	}
+	return false
+}
+
+// negotiateEncoding picks the content coding to use from the request's Accept-Encoding header, or "" for none
+func negotiateEncoding(r *http.Request) string {
+	header := r.Header.Get("Accept-Encoding")
+	if header == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, element := range strings.Split(header, ",") {
+		fields := strings.Split(element, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range compressionEncodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func compressBytes(encoding string, b []byte) ([]byte, error) {
+	buff := new(bytes.Buffer)
+	var w io.WriteCloser
+	if encoding == "gzip" {
+		w = gzip.NewWriter(buff)
+	} else {
+		w, _ = flate.NewWriter(buff, flate.DefaultCompression)
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+// encodedETag tags a compressed variant, since it is a different sequence of bytes from the original
+func encodedETag(etag string, encoding string) string {
+	return strings.TrimSuffix(etag, "\"") + "-" + encoding + "\""
+}
+
+// identityETag strips the encoding from a compressed variant's tag
+func identityETag(etag string) string {
+	for _, encoding := range compressionEncodings {
+		if suffix := "-" + encoding + "\""; strings.HasSuffix(etag, suffix) {
+			return strings.TrimSuffix(etag, suffix) + "\""
+		}
+	}
+	return etag
+}
+
+func addVary(w http.ResponseWriter, header string) {
+	for _, value := range w.Header().Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), header) {
+				return
+			}
+		}
+	}
+	w.Header().Add("Vary", header)
+}
+
+func shouldCompress(w http.ResponseWriter) bool {
+	return getCompressionThreshold() >= 0 && isCompressible(w.Header().Get("Content-Type")) && w.Header().Get("Content-Encoding") == ""
+}
+
+// varyEncoding marks responses which could be compressed, since they then differ by encoding whether or not this one
+// is compressed
+func varyEncoding(w http.ResponseWriter) {
+	if shouldCompress(w) {
+		addVary(w, "Accept-Encoding")
+	}
+}
+
+// responseEncoding picks the encoding for a representation of the given size, or "" to send it as is
+func responseEncoding(w http.ResponseWriter, r *http.Request, size int) string {
+	if !shouldCompress(w) || size < getCompressionThreshold() {
+		return ""
+	}
+	return negotiateEncoding(r)
+}
+
+// variantETag returns the tag of the variant a client would be sent, given the resource's own tag, if it can be known
+// before the representation is rendered. Whether a representation is compressed usually depends on its size, so it
+// often can't.
+func variantETag(w http.ResponseWriter, r *http.Request, etag string) (string, bool) {
+	encoding := negotiateEncoding(r)
+	if encoding == "" || getCompressionThreshold() < 0 || w.Header().Get("Content-Encoding") != "" {
+		return etag, true
+	}
+	mediaType := w.Header().Get("Content-Type")
+	if format := requestFormat(r); mediaType == "" {
+		if _, ok := getEncoder(format); !ok && mime.TypeByExtension("."+format) == "" {
+			// The type will be sniffed from the representation itself
+			return "", false
+		}
+		mediaType = contentType(format, nil)
+	}
+	switch {
+	case !isCompressible(mediaType):
+		return etag, true
+	case getCompressionThreshold() == 0:
+		return encodedETag(etag, encoding), true
+	}
+	return "", false
+}
+
+// compressResponse compresses the body with the chosen encoding, if any, setting the corresponding headers. It
+// returns the body and ETag to send.
+func compressResponse(w http.ResponseWriter, r *http.Request, body []byte, encoding string, etag string) ([]byte, string) {
+	if encoding == "" {
+		return body, etag
+	}
+	compressed, err := compressBytes(encoding, body)
+	if err != nil {
+		logf(r.Context(), "Unable to compress response: %v", err)
+		return body, identityETag(etag)
+	}
+	w.Header().Set("Content-Encoding", encoding)
+	return compressed, etag
+}
@@ -0,0 +1,129 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+func encodedRequest(rawurl string, acceptEncoding string) *http.Request {
+	req := request(rawurl)
+	req.Header = http.Header{"Accept-Encoding": {acceptEncoding}}
+	return req
+}
+
+func marshall(i interface{}, req *http.Request) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	err := MarshallResponse(i, resp, req)
+	Expect(err).To(BeNil())
+	return resp
+}
+
+var _ = Describe("compress.go", func() {
+	neuromancer := book{"Neuromancer", "Gibson, William"}
+	BeforeEach(func() {
+		SetCompressionThreshold(10)
+	})
+	AfterEach(func() {
+		SetCompressionThreshold(1024)
+	})
+	Describe("compressing a response", func() {
+		It("should use gzip if accepted", func() {
+			// Exercise
+			resp := marshall(neuromancer, encodedRequest("http://localhost:8080/book?fmt=json", "gzip"))
+			// Verify
+			Expect(resp.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(resp.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(resp.Header().Get("Content-Length")).To(Equal(strconv.Itoa(resp.Body.Len())))
+			reader, err := gzip.NewReader(resp.Body)
+			Expect(err).To(BeNil())
+			body, err := io.ReadAll(reader)
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should use deflate if preferred", func() {
+			// Exercise
+			resp := marshall(neuromancer, encodedRequest("http://localhost:8080/book?fmt=json", "gzip;q=0.5, deflate"))
+			// Verify
+			Expect(resp.Header().Get("Content-Encoding")).To(Equal("deflate"))
+			body, err := io.ReadAll(flate.NewReader(resp.Body))
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should not use a refused encoding", func() {
+			// Exercise
+			resp := marshall(neuromancer, encodedRequest("http://localhost:8080/book?fmt=json", "*, gzip;q=0, deflate;q=0"))
+			// Verify
+			Expect(resp.Header().Get("Content-Encoding")).To(Equal(""))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should not compress below the threshold", func() {
+			// Setup
+			SetCompressionThreshold(1024)
+			// Exercise
+			resp := marshall(neuromancer, encodedRequest("http://localhost:8080/book?fmt=json", "gzip"))
+			// Verify
+			Expect(resp.Header().Get("Content-Encoding")).To(Equal(""))
+			Expect(resp.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should tag compressed variants separately", func() {
+			// Exercise
+			plain := marshall(neuromancer, request("http://localhost:8080/book?fmt=json"))
+			compressed := marshall(neuromancer, encodedRequest("http://localhost:8080/book?fmt=json", "gzip"))
+			// Verify
+			Expect(compressed.Header().Get("ETag")).To(Equal(plain.Header().Get("ETag")[:33] + "-gzip\""))
+		})
+		It("should match a compressed variant's ETag", func() {
+			// Setup
+			etag := marshall(neuromancer, encodedRequest("http://localhost:8080/book?fmt=json", "gzip")).Header().Get("ETag")
+			req := encodedRequest("http://localhost:8080/book?fmt=json", "gzip")
+			req.Header.Set("If-None-Match", etag)
+			// Exercise
+			resp := marshall(neuromancer, req)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+			Expect(resp.Header().Get("Content-Encoding")).To(Equal(""))
+			Expect(resp.Header().Get("ETag")).To(Equal(etag))
+		})
+		for _, threshold := range []int{0, 10} {
+			threshold := threshold
+			It(fmt.Sprintf("should repeat a compressed variant's ETag for a resource's own ETag with a threshold of %d", threshold), func() {
+				// Setup
+				SetCompressionThreshold(threshold)
+				versioned := versionedBook{"Neuromancer", "v1", time.Time{}}
+				req := encodedRequest("http://localhost:8080/book?fmt=json", "gzip")
+				req.Header.Set("If-None-Match", "\"v1-json-gzip\"")
+				// Exercise
+				resp := marshall(versioned, req)
+				// Verify
+				Expect(resp.Code).To(Equal(http.StatusNotModified))
+				Expect(resp.Header().Get("ETag")).To(Equal("\"v1-json-gzip\""))
+			})
+		}
+	})
+	Describe("setting the content type", func() {
+		cases := map[string]string{
+			"json": "application/json; charset=utf-8",
+			"html": "text/html; charset=utf-8",
+			"text": "text/plain; charset=utf-8"}
+		for k, v := range cases {
+			format, expected := k, v
+			It("should use "+expected+" for "+format, func() {
+				// Exercise
+				resp := marshall(neuromancer, request("http://localhost:8080/book?fmt="+format))
+				// Verify
+				Expect(resp.Header().Get("Content-Type")).To(Equal(expected))
+			})
+		}
+	})
+})
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagger is implemented by resources which can identify their current state more cheaply than by rendering it. The
+// tag is qualified with the format, since each format is a separate representation.
+type ETagger interface {
+	ETag() string
+}
+
+// LastModifier is implemented by resources which know when they last changed
+type LastModifier interface {
+	LastModified() time.Time
+}
+
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+func quoteETag(tag string) string {
+	return "\"" + tag + "\""
+}
+
+func resourceValidators(i interface{}, format string) validators {
+	var v validators
+	if e, ok := i.(ETagger); ok {
+		if tag := strings.Trim(e.ETag(), "\""); tag != "" {
+			v.etag = quoteETag(tag + "-" + format)
+		}
+	}
+	if m, ok := i.(LastModifier); ok {
+		// HTTP dates only have a resolution of seconds
+		v.lastModified = m.LastModified().UTC().Truncate(time.Second)
+	}
+	return v
+}
+
+// headerValidators reads back the validators of a response which has already been rendered
+func headerValidators(h http.Header) validators {
+	v := validators{etag: h.Get("ETag")}
+	if modified, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		v.lastModified = modified
+	}
+	return v
+}
+
+func bytesETag(bytes []byte) string {
+	sum := sha256.Sum256(bytes)
+	return quoteETag(hex.EncodeToString(sum[:16]))
+}
+
+// etagMatches performs the weak comparison required for If-None-Match
+func etagMatches(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || identityETag(strings.TrimPrefix(candidate, "W/")) == identityETag(strings.TrimPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeMethod(r *http.Request) bool {
+	return r.Method == "" || r.Method == "GET" || r.Method == "HEAD"
+}
+
+func notModified(r *http.Request, v validators) bool {
+	if !isSafeMethod(r) {
+		return false
+	}
+	// If-Modified-Since is ignored when If-None-Match is present
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, v.etag)
+	}
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !v.lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !v.lastModified.After(since)
+	}
+	return false
+}
+
+// writeValidators sets the validator headers and, if the client's copy is current, writes a 304 response
+func writeValidators(w http.ResponseWriter, r *http.Request, v validators) bool {
+	if v.etag != "" {
+		w.Header().Set("ETag", v.etag)
+	}
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.Format(http.TimeFormat))
+	}
+	if !notModified(r, v) {
+		return false
+	}
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+type versionedBook struct {
+	Title    string `json:"title"`
+	version  string
+	modified time.Time
+}
+
+func (b versionedBook) ETag() string {
+	return b.version
+}
+
+func (b versionedBook) LastModified() time.Time {
+	return b.modified
+}
+
+func conditionalRequest(rawurl string, header string, value string) *http.Request {
+	req := request(rawurl)
+	req.Header = http.Header{}
+	req.Header.Set(header, value)
+	return req
+}
+
+var _ = Describe("conditional.go", func() {
+	modified := time.Date(2014, time.March, 1, 12, 30, 15, 0, time.UTC)
+	Describe("rendering a resource", func() {
+		It("should set an ETag computed from the representation", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			err := MarshallResponse(book{"Neuromancer", "Gibson, William"}, resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(resp.Header().Get("ETag")).To(MatchRegexp("^\"[0-9a-f]{32}\"$"))
+		})
+		It("should set different ETags for different formats", func() {
+			// Exercise
+			jsonResp, textResp := httptest.NewRecorder(), httptest.NewRecorder()
+			MarshallResponse(book{"Neuromancer", "Gibson, William"}, jsonResp, request("http://localhost:8080/book?fmt=json"))
+			MarshallResponse(book{"Neuromancer", "Gibson, William"}, textResp, request("http://localhost:8080/book?fmt=text"))
+			// Verify
+			Expect(jsonResp.Header().Get("ETag")).ToNot(Equal(textResp.Header().Get("ETag")))
+		})
+		It("should use the resource's ETag and modification time", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			MarshallResponse(versionedBook{"Neuromancer", "v1", modified}, resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Header().Get("ETag")).To(Equal("\"v1-json\""))
+			Expect(resp.Header().Get("Last-Modified")).To(Equal("Sat, 01 Mar 2014 12:30:15 GMT"))
+		})
+	})
+	Describe("handling a conditional GET", func() {
+		It("should return 304 if the ETag matches", func() {
+			// Setup
+			first := httptest.NewRecorder()
+			MarshallResponse(book{"Neuromancer", "Gibson, William"}, first, request("http://localhost:8080/book?fmt=json"))
+			// Exercise
+			resp := httptest.NewRecorder()
+			err := MarshallResponse(book{"Neuromancer", "Gibson, William"}, resp,
+				conditionalRequest("http://localhost:8080/book?fmt=json", "If-None-Match", "\"other\", "+first.Header().Get("ETag")))
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+			Expect(resp.Body.String()).To(Equal(""))
+		})
+		It("should return the representation if the ETag doesn't match", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			MarshallResponse(book{"Neuromancer", "Gibson, William"}, resp,
+				conditionalRequest("http://localhost:8080/book?fmt=json", "If-None-Match", "\"other\""))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should match the resource's ETag without rendering", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			err := MarshallResponse(versionedBook{"Neuromancer", "v1", modified}, resp,
+				conditionalRequest("http://localhost:8080/book?fmt=missing", "If-None-Match", "W/\"v1-missing\""))
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+		})
+		It("should return 304 if not modified since", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			MarshallResponse(versionedBook{"Neuromancer", "", modified}, resp,
+				conditionalRequest("http://localhost:8080/book?fmt=json", "If-Modified-Since", "Sat, 01 Mar 2014 12:30:15 GMT"))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+		})
+		It("should return the representation if modified since", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			MarshallResponse(versionedBook{"Neuromancer", "", modified}, resp,
+				conditionalRequest("http://localhost:8080/book?fmt=json", "If-Modified-Since", "Sat, 01 Mar 2014 12:30:14 GMT"))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\"}"))
+		})
+	})
+})
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder writes a representation of a resource. Formats without an encoder are rendered using templates.
+type Encoder func(i interface{}, w io.Writer, r *http.Request) *RequestError
+
+type encoderEntry struct {
+	mediaType string
+	encoder   Encoder
+}
+
+var encoderMutex sync.RWMutex
+var encoders = map[string]encoderEntry{
+	"json":     {"application/json; charset=utf-8", jsonEncoder},
+	"ndjson":   {"application/x-ndjson", recordEncoder("", "\n")},
+	"json-seq": {"application/json-seq", recordEncoder("\x1e", "\n")},
+	"hal":      {"application/hal+json", halEncoder},
+	"jsonapi":  {"application/vnd.api+json", jsonAPIEncoder}}
+
+// RegisterEncoder makes format available using the given encoder, in preference to any templates for it. Clients
+// may select it with the fmt parameter, or by sending mediaType in their Accept header.
+func RegisterEncoder(format string, mediaType string, encoder Encoder) {
+	encoderMutex.Lock()
+	defer encoderMutex.Unlock()
+
+	encoders[format] = encoderEntry{mediaType, encoder}
+}
+
+func getEncoder(format string) (encoderEntry, bool) {
+	encoderMutex.RLock()
+	defer encoderMutex.RUnlock()
+
+	entry, ok := encoders[format]
+	return entry, ok
+}
+
+func encoderFormats() []string {
+	encoderMutex.RLock()
+	defer encoderMutex.RUnlock()
+
+	formats := make([]string, 0, len(encoders))
+	for format := range encoders {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func jsonEncoder(i interface{}, w io.Writer, r *http.Request) *RequestError {
+	return writeJson(r.Context(), i, w)
+}
+
+func isRecordFormat(format string) bool {
+	return format == "ndjson" || format == "json-seq"
+}
+
+// recordEncoder writes each element of a collection as a separate JSON text, flushing after each element of a lazy
+// sequence so that clients receive them as they're produced
+func recordEncoder(prefix string, suffix string) Encoder {
+	return func(i interface{}, w io.Writer, r *http.Request) *RequestError {
+		ctx := r.Context()
+		lazy := isSequence(i)
+		write := func(element interface{}) error {
+			if _, err := io.WriteString(w, prefix); err != nil {
+				return err
+			}
+			if err := writeJsonValue(ctx, element, w); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, suffix); err != nil {
+				return err
+			}
+			if f, ok := w.(http.Flusher); ok && lazy {
+				f.Flush()
+			}
+			return nil
+		}
+
+		var err error
+		if v := reflect.ValueOf(i); lazy {
+			err = forEach(ctx, i, write)
+		} else if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			for idx := 0; idx < v.Len() && err == nil; idx++ {
+				err = write(v.Index(idx).Interface())
+			}
+		} else {
+			err = write(i)
+		}
+		if err != nil {
+			logf(ctx, "Unable to write records: %v", err)
+			return internalRequestError(err)
+		}
+		return nil
+	}
+}
+
+type acceptedType struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(header string) []acceptedType {
+	var accepted []acceptedType
+	for _, element := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(element))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, acceptedType{mediaType, q})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+	return accepted
+}
+
+// formatForMediaType finds the format for a media type, preferring registered encoders over template extensions
+func formatForMediaType(mediaType string) string {
+	for _, format := range encoderFormats() {
+		entry, _ := getEncoder(format)
+		if t, _, err := mime.ParseMediaType(entry.mediaType); err == nil && t == mediaType {
+			return format
+		}
+	}
+
+	extensions, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(extensions) == 0 {
+		return ""
+	}
+	// Where there are several extensions, the one matching the subtype is usually canonical e.g. html rather than htm
+	subtype := mediaType[strings.Index(mediaType, "/")+1:]
+	for _, extension := range extensions {
+		if extension == "."+subtype {
+			return subtype
+		}
+	}
+	return strings.TrimPrefix(extensions[0], ".")
+}
+
+// negotiateFormat picks a format from the request's Accept header, or "" if there is no acceptable one
+func negotiateFormat(r *http.Request) string {
+	for _, accepted := range parseAccept(r.Header.Get("Accept")) {
+		if format := formatForMediaType(accepted.mediaType); format != "" {
+			return format
+		}
+	}
+	return ""
+}
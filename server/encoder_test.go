@@ -0,0 +1,114 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (f *flushCounter) Flush() {
+	f.flushes++
+	f.ResponseRecorder.Flush()
+}
+
+func titleEncoder(i interface{}, w io.Writer, r *http.Request) *RequestError {
+	if b, ok := i.(book); ok {
+		fmt.Fprintf(w, "Title: %s", b.Title)
+		return nil
+	}
+	return &RequestError{Error: fmt.Errorf("Not a book"), Message: "Not a book", Code: http.StatusNotAcceptable}
+}
+
+func acceptRequest(rawurl string, accept string) *http.Request {
+	req := request(rawurl)
+	req.Header = http.Header{"Accept": {accept}}
+	return req
+}
+
+var _ = Describe("encoder.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("writing records", func() {
+		It("should write each element of a collection on a separate line", func() {
+			// Exercise
+			resp := marshall(books, request("http://localhost:8080/book?fmt=ndjson"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}\n{\"title\":\"Pandora's Star\",\"author\":\"Peter F. Hamilton\"}\n"))
+		})
+		It("should write a single resource as one record", func() {
+			// Exercise
+			resp := marshall(books[0], request("http://localhost:8080/book?fmt=ndjson"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}\n"))
+		})
+		It("should write JSON text sequences", func() {
+			// Exercise
+			resp := marshall(books, request("http://localhost:8080/book?fmt=json-seq"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/json-seq"))
+			Expect(resp.Body.String()).To(Equal("\x1e{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}\n\x1e{\"title\":\"Pandora's Star\",\"author\":\"Peter F. Hamilton\"}\n"))
+		})
+		It("should flush after each element of a lazy sequence", func() {
+			// Setup
+			resp := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+			// Exercise
+			err := MarshallResponse(bookSeq(), resp, request("http://localhost:8080/book?fmt=ndjson"))
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(resp.flushes).To(Equal(2))
+			Expect(resp.Header().Get("ETag")).To(Equal(""))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}\n{\"title\":\"Pandora's Star\",\"author\":\"Peter F. Hamilton\"}\n"))
+		})
+	})
+	Describe("negotiating a format", func() {
+		It("should use the Accept header if there is no fmt parameter", func() {
+			// Setup
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return bookChannel(books[0], books[1]), nil
+			})
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, acceptRequest("http://localhost:8080/book", "application/json;q=0.5, application/x-ndjson"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+			Expect(resp.Header().Values("Vary")).To(Equal([]string{"Accept", "Accept-Encoding"}))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}\n{\"title\":\"Pandora's Star\",\"author\":\"Peter F. Hamilton\"}\n"))
+		})
+		It("should map media types to template formats", func() {
+			// Exercise
+			resp := marshall(books[0], acceptRequest("http://localhost:8080/book", "text/html"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal("<html><body>Neuromancer by Gibson, William</body></html>"))
+		})
+		It("should prefer the fmt parameter", func() {
+			// Exercise
+			resp := marshall(books[0], acceptRequest("http://localhost:8080/book?fmt=json", "text/html"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+			Expect(resp.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+		})
+	})
+	Describe("registering an encoder", func() {
+		It("should use the encoder for its format", func() {
+			// Setup
+			RegisterEncoder("title", "text/x-title", titleEncoder)
+			// Exercise
+			resp := marshall(books[0], acceptRequest("http://localhost:8080/book", "text/x-title"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("text/x-title"))
+			Expect(resp.Body.String()).To(Equal("Title: Neuromancer"))
+		})
+	})
+})
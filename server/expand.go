@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// maxExpandDepth limits how far references are followed e.g. ?expand=author.publisher has a depth of 2
+const maxExpandDepth = 3
+
+// expansion is the tree of references to expand, by field name
+type expansion map[string]expansion
+
+func parseExpansion(value string) (expansion, error) {
+	tree := expansion{}
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		names := strings.Split(path, ".")
+		if len(names) > maxExpandDepth {
+			return nil, fmt.Errorf("'%s' is nested more than %d deep", path, maxExpandDepth)
+		}
+		node := tree
+		for _, name := range names {
+			if node[name] == nil {
+				node[name] = expansion{}
+			}
+			node = node[name]
+		}
+	}
+	return tree, nil
+}
+
+// referencedEntry returns the resource referred to by a field, which is one named after a resource with a single
+// parameter
+func referencedEntry(name string) (mutexEntry, bool) {
+	entry := defaultHandlerMutex.getHandler(name)
+	return entry, entry.handler != nil && len(entry.parameters) == 1
+}
+
+// checkExpansion checks each reference can be expanded from a resource of type t, where t is known before rendering
+func checkExpansion(t reflect.Type, tree expansion) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	for name, subtree := range tree {
+		entry, isReference := referencedEntry(name)
+		if !isReference || t.Kind() != reflect.Struct || !hasJsonField(t, name) {
+			return fmt.Errorf("'%s' can't be expanded", name)
+		}
+		if err := checkExpansion(entry.resourceType, subtree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasJsonField(t reflect.Type, name string) bool {
+	for idx := 0; idx < t.NumField(); idx++ {
+		if fieldName, ok := jsonFieldName(t.Field(idx)); ok && fieldName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// expander inlines referenced resources, fetching each one once per request however often it's referred to
+type expander struct {
+	ctx     context.Context
+	fetched map[string]interface{}
+}
+
+func (e *expander) fetch(entry mutexEntry, id string) (interface{}, *RequestError) {
+	key := entry.typeName + "/" + id
+	if resource, ok := e.fetched[key]; ok {
+		return resource, nil
+	}
+	resource, err := callHandler(e.ctx, entry, parameterMap{entry.parameters[0]: id}, entry.handler)
+	if err != nil {
+		logf(e.ctx, "Unable to expand [%s]: %v", key, err.Error)
+		return nil, err
+	}
+	e.fetched[key] = resource
+	return resource, nil
+}
+
+func (e *expander) expand(i interface{}, tree expansion) (interface{}, *RequestError) {
+	if len(tree) == 0 {
+		return i, nil
+	}
+	members, isObject, err := objectMembers(i)
+	if err != nil {
+		return nil, internalRequestError(err)
+	}
+	if !isObject {
+		return i, nil
+	}
+	for idx, member := range members {
+		subtree, ok := tree[member.key]
+		if !ok {
+			continue
+		}
+		entry, isReference := referencedEntry(member.key)
+		id := fieldText(i, member.key)
+		if !isReference || id == "" {
+			continue
+		}
+		resource, err := e.fetch(entry, id)
+		if err != nil {
+			return nil, err
+		}
+		if members[idx].value, err = e.expand(resource, subtree); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+// expandResources inlines the resources the client asked for with ?expand=author in place of the fields referring to
+// them, for formats with an encoder. A field refers to a resource if it's named after a registered resource with a
+// single parameter, and its value is that parameter. It reports whether anything was expanded.
+func expandResources(i interface{}, r *http.Request) (interface{}, bool, *RequestError) {
+	value := r.URL.Query().Get("expand")
+	if _, ok := getEncoder(requestFormat(r)); !ok || value == "" || i == nil {
+		return i, false, nil
+	}
+	tree, err := parseExpansion(value)
+	if err != nil {
+		return nil, false, badRequest(err)
+	}
+	e := &expander{ctx: r.Context(), fetched: make(map[string]interface{})}
+	v := reflect.ValueOf(i)
+
+	if isSequence(i) {
+		if err := checkExpansion(sequenceElementType(v.Type()), tree); err != nil {
+			return nil, false, badRequest(err)
+		}
+		// The response may have started by the time an element fails to expand, so it's left as it is
+		stop := errors.New("stop")
+		seq := func(yield func(interface{}) bool) {
+			forEach(e.ctx, i, func(element interface{}) error {
+				if expanded, err := e.expand(element, tree); err == nil {
+					element = expanded
+				}
+				if !yield(element) {
+					return stop
+				}
+				return nil
+			})
+		}
+		return seq, true, nil
+	}
+
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		if err := checkExpansion(v.Type(), tree); err != nil {
+			return nil, false, badRequest(err)
+		}
+		expanded, err := e.expand(i, tree)
+		return expanded, err == nil, err
+	}
+	if err := checkExpansion(v.Type().Elem(), tree); err != nil {
+		return nil, false, badRequest(err)
+	}
+	expanded := make([]interface{}, v.Len())
+	for idx := range expanded {
+		element := v.Index(idx).Interface()
+		if err := checkExpansion(reflect.TypeOf(element), tree); err != nil {
+			return nil, false, badRequest(err)
+		}
+		var err *RequestError
+		if expanded[idx], err = e.expand(element, tree); err != nil {
+			return nil, false, err
+		}
+	}
+	return expanded, true, nil
+}
@@ -0,0 +1,110 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type novel struct {
+	Title  string `json:"title"`
+	Series string `json:"series"`
+}
+
+type series struct {
+	Name   string `json:"name"`
+	Author string `json:"author"`
+}
+
+func createCountingAuthorHandler(count *int) GetHandler {
+	return func(params PathParameters) (interface{}, *RequestError) {
+		*count++
+		return authorHandler(params)
+	}
+}
+
+var _ = Describe("expand.go", func() {
+	var count int
+	BeforeEach(func() {
+		count = 0
+		Resource(author{}, "/{name}", createCountingAuthorHandler(&count))
+	})
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("expanding a reference", func() {
+		It("should inline the referenced resource", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&expand=author")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`{"title":"Neuromancer","author":{"name":"Gibson, William"}}`))
+		})
+		It("should fetch each referenced resource once", func() {
+			// Setup
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return []book{
+					book{"Neuromancer", "Gibson, William"},
+					book{"Count Zero", "Gibson, William"}}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&expand=author")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`[{"title":"Neuromancer","author":{"name":"Gibson, William"}},` +
+				`{"title":"Count Zero","author":{"name":"Gibson, William"}}]`))
+			Expect(count).To(Equal(1))
+		})
+		It("should expand nested references", func() {
+			// Setup
+			Resource(series{}, "/{name}", func(params PathParameters) (interface{}, *RequestError) {
+				name, _ := params.Get("name")
+				return series{name, "Gibson, William"}, nil
+			})
+			SingletonResource(novel{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return novel{"Neuromancer", "Sprawl"}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/novel?fmt=json&expand=series.author")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(
+				`{"title":"Neuromancer","series":{"name":"Sprawl","author":{"name":"Gibson, William"}}}`))
+		})
+		It("should expand selected fields", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&fields=author&expand=author")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`{"author":{"name":"Gibson, William"}}`))
+		})
+		It("should fail when the referenced resource can't be fetched", func() {
+			// Setup
+			Resource(author{}, "/{name}", func(_ PathParameters) (interface{}, *RequestError) {
+				return nil, &RequestError{Message: "No such author", Code: 404}
+			})
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&expand=author")
+			// Verify
+			Expect(resp.Code).To(Equal(404))
+		})
+		It("should reject fields which don't refer to a resource", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&expand=title")
+			// Verify
+			Expect(resp.Code).To(Equal(400))
+			Expect(resp.Body.String()).To(ContainSubstring(`'title' can't be expanded`))
+		})
+		It("should reject references nested too deeply", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&expand=author.a.b.c")
+			// Verify
+			Expect(resp.Code).To(Equal(400))
+		})
+	})
+})
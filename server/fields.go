@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// projection copies the requested fields of a struct into a struct type with only those fields
+type projection struct {
+	t       reflect.Type
+	indices []int
+}
+
+func newProjection(t reflect.Type, fields []string, lenient bool) (*projection, error) {
+	p := &projection{}
+	var projected []reflect.StructField
+	found := make(map[string]bool)
+	for idx := 0; idx < t.NumField(); idx++ {
+		f := t.Field(idx)
+		if name, ok := jsonFieldName(f); ok && contains(fields, name) {
+			projected = append(projected, reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag})
+			p.indices = append(p.indices, idx)
+			found[name] = true
+		}
+	}
+	for _, field := range fields {
+		if !found[field] && !lenient {
+			return nil, fmt.Errorf("'%s' is not a field of %s", field, strings.ToLower(t.Name()))
+		}
+	}
+	p.t = reflect.StructOf(projected)
+	return p, nil
+}
+
+func (p *projection) project(v reflect.Value) interface{} {
+	out := reflect.New(p.t).Elem()
+	for k, idx := range p.indices {
+		out.Field(k).Set(v.Field(idx))
+	}
+	return out.Interface()
+}
+
+// projector trims values to the requested fields, building a projection for each struct type it meets. A lenient
+// projector ignores fields which the value doesn't have, and values which aren't structs.
+type projector struct {
+	fields      []string
+	lenient     bool
+	projections map[reflect.Type]*projection
+}
+
+func (pr *projector) project(v reflect.Value) (interface{}, error) {
+	v = elementValue(v)
+	if v.Kind() != reflect.Struct {
+		if !v.IsValid() || v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			return nil, nil
+		}
+		if pr.lenient {
+			return v.Interface(), nil
+		}
+		return nil, errors.New("fields can only be selected from structs")
+	}
+	p, ok := pr.projections[v.Type()]
+	if !ok {
+		var err error
+		if p, err = newProjection(v.Type(), pr.fields, pr.lenient); err != nil {
+			return nil, err
+		}
+		pr.projections[v.Type()] = p
+	}
+	return p.project(v), nil
+}
+
+func sequenceElementType(t reflect.Type) reflect.Type {
+	switch {
+	case t.Kind() == reflect.Chan:
+		return t.Elem()
+	case isPushIterator(t):
+		return t.In(0).In(0)
+	}
+	return t.Out(0)
+}
+
+// checkElementType checks the fields can be selected from a collection's elements, if their type is known before
+// rendering them
+func (pr *projector) checkElementType(t reflect.Type) *RequestError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+	if _, err := pr.project(reflect.New(t)); err != nil {
+		return badRequest(err)
+	}
+	return nil
+}
+
+func requestFields(r *http.Request) []string {
+	var fields []string
+	for _, field := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func badRequest(err error) *RequestError {
+	return &RequestError{Error: err, Message: err.Error(), Code: http.StatusBadRequest}
+}
+
+// projectFields trims a resource, or each element of a collection, to the fields the client selected with
+// ?fields=title,author. Only formats with an encoder are trimmed, since templates are written for the whole resource.
+// It reports whether the resource was trimmed. The elements of a lazy sequence whose type is only known as each is
+// produced are trimmed leniently, since the response may have started before an unsuitable one arrives.
+func projectFields(i interface{}, r *http.Request) (interface{}, bool, *RequestError) {
+	fields := requestFields(r)
+	if _, ok := getEncoder(requestFormat(r)); !ok || len(fields) == 0 || i == nil {
+		return i, false, nil
+	}
+	pr := &projector{fields: fields, projections: make(map[reflect.Type]*projection)}
+	v := reflect.ValueOf(i)
+
+	if isSequence(i) {
+		if err := pr.checkElementType(sequenceElementType(v.Type())); err != nil {
+			return nil, false, err
+		}
+		pr.lenient = true
+		ctx := r.Context()
+		stop := errors.New("stop")
+		seq := func(yield func(interface{}) bool) {
+			forEach(ctx, i, func(element interface{}) error {
+				projected, _ := pr.project(reflect.ValueOf(element))
+				if !yield(projected) {
+					return stop
+				}
+				return nil
+			})
+		}
+		return seq, true, nil
+	}
+
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		projected, err := pr.project(v)
+		if err != nil {
+			return nil, false, badRequest(err)
+		}
+		return projected, true, nil
+	}
+	if err := pr.checkElementType(v.Type().Elem()); err != nil {
+		return nil, false, err
+	}
+	projected := make([]interface{}, v.Len())
+	for idx := range projected {
+		var err error
+		if projected[idx], err = pr.project(v.Index(idx)); err != nil {
+			return nil, false, badRequest(err)
+		}
+	}
+	return projected, true, nil
+}
@@ -0,0 +1,88 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("fields.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("selecting fields", func() {
+		It("should trim a resource to the selected fields", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&fields=title")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`{"title":"Neuromancer"}`))
+		})
+		It("should keep the order of the resource's fields", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&fields=author,title")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`{"title":"Neuromancer","author":"Gibson, William"}`))
+		})
+		It("should trim each element of a collection", func() {
+			// Setup
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return books, nil
+			})
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&fields=author")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`[{"author":"Gibson, William"},{"author":"Peter F. Hamilton"}]`))
+		})
+		It("should trim each element of a sequence", func() {
+			// Setup
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return bookSeq(), nil
+			})
+			// Exercise
+			resp := get("http://localhost/book?fmt=ndjson&fields=title")
+			// Verify
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\"}\n{\"title\":\"Pandora's Star\"}\n"))
+		})
+		It("should not trim template formats", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=text&fields=title")
+			// Verify
+			Expect(resp.Body.String()).To(ContainSubstring("Gibson, William"))
+		})
+		It("should reject unknown fields", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&fields=title,isbn")
+			// Verify
+			Expect(resp.Code).To(Equal(400))
+			Expect(resp.Body.String()).To(ContainSubstring(`'isbn' is not a field of book`))
+		})
+		It("should reject unknown fields of an empty collection", func() {
+			// Setup
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return []book{}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&fields=isbn")
+			// Verify
+			Expect(resp.Code).To(Equal(400))
+		})
+		It("should not use the resource's ETag", func() {
+			// Setup
+			SingletonResource(versionedBook{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return versionedBook{Title: "Neuromancer", version: "v1"}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/versionedBook?fmt=json&fields=title")
+			// Verify
+			Expect(resp.Header().Get("ETag")).NotTo(Equal(`"v1-json"`))
+		})
+	})
+})
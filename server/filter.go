@@ -0,0 +1,234 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Query parameters with a meaning to the framework, which are never treated as filters
+var reservedParameters = map[string]bool{"fmt": true, "sort": true, "page": true, "limit": true, "cursor": true, "fields": true, "expand": true}
+
+// Sortable allows clients to sort a collection resource by the named fields e.g. ?sort=-title,author. Fields are named
+// as they are in the resource's JSON representation, and a leading '-' sorts in descending order.
+func Sortable(fields ...string) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.sortable = fields
+	}
+}
+
+// Filterable allows clients to filter a collection resource by the named fields e.g. ?author=Gibson*, where '*' matches
+// any text. Any other query parameters are then rejected.
+func Filterable(fields ...string) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.filterable = fields
+	}
+}
+
+// jsonFieldName returns the name of a struct field in its JSON representation, or false if it isn't represented
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+func elementValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fieldByJsonName finds the named field of a struct, returning an invalid value if there isn't one
+func fieldByJsonName(v reflect.Value, name string) reflect.Value {
+	v = elementValue(v)
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	for idx := 0; idx < v.NumField(); idx++ {
+		if fieldName, ok := jsonFieldName(v.Type().Field(idx)); ok && fieldName == name {
+			return v.Field(idx)
+		}
+	}
+	return reflect.Value{}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownField(use string, name string) *RequestError {
+	return &RequestError{Error: fmt.Errorf("Invalid %s field [%s]", use, name), Message: fmt.Sprintf("'%s' is not a %s field", name, use), Code: http.StatusBadRequest}
+}
+
+type sortKey struct {
+	field      string
+	descending bool
+}
+
+func parseSort(value string, sortable []string) ([]sortKey, *RequestError) {
+	var keys []sortKey
+	for _, field := range strings.Split(value, ",") {
+		key := sortKey{field: strings.TrimSpace(field)}
+		if strings.HasPrefix(key.field, "-") {
+			key.field, key.descending = key.field[1:], true
+		}
+		if key.field == "" {
+			continue
+		}
+		if !contains(sortable, key.field) {
+			return nil, unknownField("sortable", key.field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// isMissing reports whether a value, after elementValue, has nothing to compare e.g. a nil pointer
+func isMissing(v reflect.Value) bool {
+	return !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil())
+}
+
+// compareValues orders numbers numerically and everything else by its text. Elements without a value sort last in
+// either direction.
+func compareValues(a reflect.Value, b reflect.Value, descending bool) int {
+	a, b = elementValue(a), elementValue(b)
+	switch {
+	case isMissing(a) && isMissing(b):
+		return 0
+	case isMissing(b):
+		return -1
+	case isMissing(a):
+		return 1
+	}
+	var c int
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c = compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		c = compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		c = compareOrdered(a.Float(), b.Float())
+	default:
+		c = strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+	}
+	if descending {
+		return -c
+	}
+	return c
+}
+
+func compareOrdered[T int64 | uint64 | float64](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// matchesWildcard reports whether text matches a pattern in which '*' matches any text
+func matchesWildcard(pattern string, text string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == text
+	}
+	if !strings.HasPrefix(text, parts[0]) {
+		return false
+	}
+	text = text[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(text, part)
+		if idx < 0 {
+			return false
+		}
+		text = text[idx+len(part):]
+	}
+	return strings.HasSuffix(text, parts[len(parts)-1])
+}
+
+// filterAndSort applies the filters and sort order requested by the client to a slice returned by a handler
+func filterAndSort(i interface{}, r *http.Request, entry mutexEntry) (interface{}, *RequestError) {
+	if entry.filterable == nil && entry.sortable == nil {
+		return i, nil
+	}
+	query := r.URL.Query()
+	filters := make(map[string][]string)
+	if entry.filterable != nil {
+		for name, values := range query {
+			if reservedParameters[name] {
+				continue
+			}
+			if !contains(entry.filterable, name) {
+				return nil, unknownField("filterable", name)
+			}
+			filters[name] = values
+		}
+	}
+	var keys []sortKey
+	if value := query.Get("sort"); value != "" {
+		var err *RequestError
+		if keys, err = parseSort(value, entry.sortable); err != nil {
+			return nil, err
+		}
+	}
+
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Slice || (len(filters) == 0 && len(keys) == 0) {
+		return i, nil
+	}
+	result := reflect.MakeSlice(v.Type(), 0, v.Len())
+	for idx := 0; idx < v.Len(); idx++ {
+		if matchesFilters(v.Index(idx), filters) {
+			result = reflect.Append(result, v.Index(idx))
+		}
+	}
+	sort.SliceStable(result.Interface(), func(a, b int) bool {
+		for _, key := range keys {
+			c := compareValues(fieldByJsonName(result.Index(a), key.field), fieldByJsonName(result.Index(b), key.field), key.descending)
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return result.Interface(), nil
+}
+
+// matchesFilters reports whether an element matches every filter, and any of the values given for each
+func matchesFilters(element reflect.Value, filters map[string][]string) bool {
+	for name, patterns := range filters {
+		field := elementValue(fieldByJsonName(element, name))
+		if isMissing(field) {
+			return false
+		}
+		text := fmt.Sprint(field.Interface())
+		matched := false
+		for _, pattern := range patterns {
+			matched = matched || matchesWildcard(pattern, text)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
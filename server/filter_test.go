@@ -0,0 +1,155 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+)
+
+type edition struct {
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+	Notes string `json:"-"`
+}
+
+var editions = []edition{
+	edition{"Neuromancer", 1984, ""},
+	edition{"Count Zero", 1986, ""},
+	edition{"Idoru", 1996, ""},
+	edition{"Mona Lisa Overdrive", 1988, ""}}
+
+type rating struct {
+	Title string `json:"title"`
+	Score *int   `json:"score"`
+}
+
+func ratingsHandler(_ PathParameters) (interface{}, *RequestError) {
+	low, high := 3, 5
+	return []rating{rating{"Idoru", nil}, rating{"Neuromancer", &high}, rating{"Count Zero", &low}}, nil
+}
+
+func editionsHandler(_ PathParameters) (interface{}, *RequestError) {
+	return editions, nil
+}
+
+func titles(body []byte) []string {
+	var decoded []edition
+	Expect(json.Unmarshal(body, &decoded)).To(Succeed())
+	result := make([]string, len(decoded))
+	for idx, e := range decoded {
+		result[idx] = e.Title
+	}
+	return result
+}
+
+var _ = Describe("filter.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("sorting a collection", func() {
+		It("should sort by a field", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Sortable("title", "year"))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&sort=title")
+			// Verify
+			Expect(titles(resp.Body.Bytes())).To(Equal([]string{"Count Zero", "Idoru", "Mona Lisa Overdrive", "Neuromancer"}))
+		})
+		It("should sort numbers numerically in descending order", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Sortable("title", "year"))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&sort=-year")
+			// Verify
+			Expect(titles(resp.Body.Bytes())).To(Equal([]string{"Idoru", "Mona Lisa Overdrive", "Count Zero", "Neuromancer"}))
+		})
+		It("should sort elements without a value last in either direction", func() {
+			// Setup
+			SingletonResource(rating{}, ratingsHandler, Sortable("score"))
+			// Exercise
+			ascending := get("http://localhost/rating?fmt=json&sort=score")
+			descending := get("http://localhost/rating?fmt=json&sort=-score")
+			// Verify
+			Expect(titles(ascending.Body.Bytes())).To(Equal([]string{"Count Zero", "Neuromancer", "Idoru"}))
+			Expect(titles(descending.Body.Bytes())).To(Equal([]string{"Neuromancer", "Count Zero", "Idoru"}))
+		})
+		It("should sort by several fields", func() {
+			// Setup
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return []book{
+					book{"Pandora's Star", "Peter F. Hamilton"},
+					book{"Neuromancer", "Gibson, William"},
+					book{"Count Zero", "Gibson, William"}}, nil
+			}, Sortable("title", "author"))
+			// Exercise
+			resp := get("http://localhost/book?fmt=json&sort=author,-title")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`[{"title":"Neuromancer","author":"Gibson, William"},` +
+				`{"title":"Count Zero","author":"Gibson, William"},` +
+				`{"title":"Pandora's Star","author":"Peter F. Hamilton"}]`))
+		})
+		It("should not modify the handler's slice", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Sortable("title"))
+			// Exercise
+			get("http://localhost/edition?fmt=json&sort=title")
+			// Verify
+			Expect(editions[0].Title).To(Equal("Neuromancer"))
+		})
+		It("should reject fields which aren't sortable", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Sortable("title"))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&sort=year")
+			// Verify
+			Expect(resp.Code).To(Equal(400))
+			Expect(resp.Body.String()).To(ContainSubstring(`'year' is not a sortable field`))
+		})
+	})
+	Describe("filtering a collection", func() {
+		It("should match exact values", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Filterable("title", "year"))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&year=1986")
+			// Verify
+			Expect(titles(resp.Body.Bytes())).To(Equal([]string{"Count Zero"}))
+		})
+		It("should match wildcards", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Filterable("title"))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&title=*o*e*")
+			// Verify
+			Expect(titles(resp.Body.Bytes())).To(Equal([]string{"Neuromancer", "Count Zero", "Mona Lisa Overdrive"}))
+		})
+		It("should match any of several values", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Filterable("year"))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&year=1984&year=1996")
+			// Verify
+			Expect(titles(resp.Body.Bytes())).To(Equal([]string{"Neuromancer", "Idoru"}))
+		})
+		It("should reject fields which aren't filterable", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Filterable("title"))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&notes=x")
+			// Verify
+			Expect(resp.Code).To(Equal(400))
+			Expect(resp.Body.String()).To(ContainSubstring(`'notes' is not a filterable field`))
+		})
+		It("should filter and sort before paginating", func() {
+			// Setup
+			SingletonResource(edition{}, editionsHandler, Filterable("title"), Sortable("year"), Paginated(1, 10))
+			// Exercise
+			resp := get("http://localhost/edition?fmt=json&title=*o*&sort=-year&page=2")
+			// Verify
+			Expect(titles(resp.Body.Bytes())).To(Equal([]string{"Mona Lisa Overdrive"}))
+			Expect(resp.Header().Get("X-Total-Count")).To(Equal("4"))
+		})
+	})
+})
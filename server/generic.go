@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Handler returns the resource of type T identified by params. Unlike a GetHandler, the compiler checks it returns a T.
+type Handler[T any] func(ctx context.Context, params PathParameters) (T, *RequestError)
+
+// CollectionHandler returns the resources of type T identified by params
+type CollectionHandler[T any] func(ctx context.Context, params PathParameters) ([]T, *RequestError)
+
+// TypedPutHandler replaces the resource identified by params with one decoded from the request body
+type TypedPutHandler[T any] func(ctx context.Context, params PathParameters, resource T) (T, *RequestError)
+
+func (handler Handler[T]) getHandler() GetHandler {
+	return func(params PathParameters) (interface{}, *RequestError) {
+		resource, err := handler(params.Context(), params)
+		if err != nil {
+			return nil, err
+		}
+		return resource, nil
+	}
+}
+
+func (handler CollectionHandler[T]) getHandler() GetHandler {
+	return func(params PathParameters) (interface{}, *RequestError) {
+		resources, err := handler(params.Context(), params)
+		if err != nil {
+			return nil, err
+		}
+		return resources, nil
+	}
+}
+
+// collection records that a resource's handler returns a slice of its type
+func collection(entry *mutexEntry) {
+	entry.collection = true
+}
+
+// Register registers a resource of type T, named after T, with the parameters in pattern e.g. "/{title}", or "" for a
+// singleton. Like Resource, it returns an error if the resource can't be registered.
+func Register[T any](pattern string, handler Handler[T], options ...ResourceOption) error {
+	if handler == nil {
+		return fmt.Errorf("No GET handler for [%s]", ResourceName(new(T)))
+	}
+	return Resource(new(T), pattern, handler.getHandler(), options...)
+}
+
+// RegisterCollection registers a resource whose representation is a collection of T, named after T
+func RegisterCollection[T any](pattern string, handler CollectionHandler[T], options ...ResourceOption) error {
+	if handler == nil {
+		return fmt.Errorf("No GET handler for [%s]", ResourceName(new(T)))
+	}
+	return Resource(new(T), pattern, handler.getHandler(), append(options, collection)...)
+}
+
+// TypedPut allows a resource of type T to be replaced with a PUT request, passing the handler the decoded T
+func TypedPut[T any](handler TypedPutHandler[T]) ResourceOption {
+	return Put(func(params PathParameters, resource interface{}) (interface{}, *RequestError) {
+		typed, ok := resource.(T)
+		if !ok && resource != nil {
+			// A resource registered with a pointer type is decoded as the type it points to
+			pointer := reflect.New(reflect.TypeOf(resource))
+			pointer.Elem().Set(reflect.ValueOf(resource))
+			typed, ok = pointer.Interface().(T)
+		}
+		if !ok {
+			return nil, internalRequestError(fmt.Errorf("TypedPut handler for [%T] given [%T]", typed, resource))
+		}
+		stored, err := handler(params.Context(), params, typed)
+		if err != nil {
+			return nil, err
+		}
+		return stored, nil
+	})
+}
@@ -0,0 +1,136 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"context"
+	"net/http"
+	"net/http/httptest"
+)
+
+func getTypedBook(_ context.Context, params PathParameters) (book, *RequestError) {
+	title, _ := params.Get("title")
+	return book{title, "Gibson, William"}, nil
+}
+
+var _ = Describe("generic.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("registering a resource by type", func() {
+		It("should name the resource after the type", func() {
+			// Setup
+			Register("/{title}", getTypedBook)
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, request("http://localhost:8080/book/Neuromancer?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should name the resource after the type a pointer refers to", func() {
+			// Setup
+			err := Register("/{title}", func(ctx context.Context, params PathParameters) (*book, *RequestError) {
+				b, err := getTypedBook(ctx, params)
+				return &b, err
+			})
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, request("http://localhost:8080/book/Neuromancer?fmt=json"))
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should pass the handler the request's context", func() {
+			// Setup
+			var id string
+			Register("", func(ctx context.Context, _ PathParameters) (book, *RequestError) {
+				id = RequestID(ctx)
+				return book{}, nil
+			})
+			req := request("http://localhost:8080/book?fmt=json")
+			req.Header = map[string][]string{"X-Request-Id": {"abc-123"}}
+			// Exercise
+			MainHandler(httptest.NewRecorder(), req)
+			// Verify
+			Expect(id).To(Equal("abc-123"))
+		})
+		It("should return the handler's error", func() {
+			// Setup
+			Register("/{title}", func(_ context.Context, _ PathParameters) (book, *RequestError) {
+				return book{}, &RequestError{Message: "Not found", Code: http.StatusNotFound}
+			})
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, request("http://localhost:8080/book/Idoru?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+	Describe("registering a collection by type", func() {
+		It("should shape the collection like any other", func() {
+			// Setup
+			RegisterCollection("", func(_ context.Context, _ PathParameters) ([]book, *RequestError) {
+				return []book{{"Count Zero", "Gibson, William"}, {"Neuromancer", "Gibson, William"}}, nil
+			}, Sortable("title"))
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json&sort=-title"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal("[{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"},{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}]"))
+		})
+		It("should describe the collection in the OpenAPI document", func() {
+			// Setup
+			RegisterCollection("", func(_ context.Context, _ PathParameters) ([]book, *RequestError) {
+				return nil, nil
+			})
+			// Exercise
+			get := OpenAPI("Books", "1.0").Paths["/book"]["get"]
+			// Verify
+			Expect(get.Responses["200"].Content["application/json"].Schema).To(Equal(&Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/book"}}))
+		})
+	})
+	Describe("replacing a resource by type", func() {
+		It("should pass the handler the decoded resource", func() {
+			// Setup
+			var replaced book
+			Register("/{title}", getTypedBook, TypedPut(func(_ context.Context, _ PathParameters, b book) (book, *RequestError) {
+				replaced = b
+				return b, nil
+			}))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Count_Zero?fmt=json", "{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(replaced).To(Equal(book{"Count Zero", "Gibson, William"}))
+		})
+		It("should pass a pointer to the decoded resource to a handler of a pointer type", func() {
+			// Setup
+			var replaced *book
+			Register("/{title}", func(_ context.Context, _ PathParameters) (*book, *RequestError) {
+				return nil, nil
+			}, TypedPut(func(_ context.Context, _ PathParameters, b *book) (*book, *RequestError) {
+				replaced = b
+				return b, nil
+			}))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Count_Zero?fmt=json", "{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(replaced).To(Equal(&book{"Count Zero", "Gibson, William"}))
+		})
+		It("should return a 500 error if the resource is of a different type", func() {
+			// Setup
+			Register("/{title}", getTypedBook, TypedPut(func(_ context.Context, _ PathParameters, a author) (author, *RequestError) {
+				return a, nil
+			}))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Count_Zero?fmt=json", "{\"title\":\"Count Zero\"}", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
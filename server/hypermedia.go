@@ -0,0 +1,290 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Link is a hypermedia link from a resource to a related URL
+type Link struct {
+	Rel  string
+	Href string
+}
+
+// Linker is implemented by resources with links beyond those found from the registry. They are added to the
+// resource's links in the hal and jsonapi formats.
+type Linker interface {
+	Links() []Link
+}
+
+// objectMember is a member of a JSON object whose order is preserved
+type objectMember struct {
+	key   string
+	value interface{}
+}
+
+type orderedObject []objectMember
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	buff := new(bytes.Buffer)
+	buff.WriteString("{")
+	for idx, member := range o {
+		if idx > 0 {
+			buff.WriteString(",")
+		}
+		key, _ := json.Marshal(member.key)
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buff.Write(key)
+		buff.WriteString(":")
+		buff.Write(value)
+	}
+	buff.WriteString("}")
+	return buff.Bytes(), nil
+}
+
+// objectMembers returns the members of a resource's JSON representation in order, or false if it isn't an object
+func objectMembers(i interface{}) (orderedObject, bool, error) {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return nil, false, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if token, _ := dec.Token(); token != json.Delim('{') {
+		return nil, false, nil
+	}
+	var members orderedObject
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false, err
+		}
+		members = append(members, objectMember{key.(string), value})
+	}
+	return members, true, nil
+}
+
+func fieldText(element interface{}, name string) string {
+	field := elementValue(fieldByJsonName(reflect.ValueOf(element), name))
+	if !field.IsValid() || field.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// hypermediaResource is a resource with the links found for it
+type hypermediaResource struct {
+	value interface{}
+	id    string
+	self  string
+	// related links are to other registered resources, identified by the resource's fields
+	related []Link
+	custom  []Link
+}
+
+func newHypermediaResource(element interface{}, entry mutexEntry, self string) hypermediaResource {
+	h := hypermediaResource{value: element, self: self}
+	// Without parameters, there's no way to address an element of a collection
+	path, err := entryURL(entry, func(parameter string) (string, bool) { return fieldText(element, parameter), true })
+	if err == nil && len(entry.parameters) > 0 {
+		if h.self == "" {
+			h.self = path
+		}
+		ids := make([]string, len(entry.parameters))
+		for idx, parameter := range entry.parameters {
+			ids[idx] = fieldText(element, parameter)
+		}
+		h.id = strings.Join(ids, "/")
+	}
+
+	// A field named after a resource with a single parameter is taken to identify one of them
+	if v := elementValue(reflect.ValueOf(element)); v.Kind() == reflect.Struct {
+		for idx := 0; idx < v.NumField(); idx++ {
+			name, ok := jsonFieldName(v.Type().Field(idx))
+			if !ok || name == entry.typeName {
+				continue
+			}
+			related := defaultHandlerMutex.getHandler(name)
+			if related.handler == nil || len(related.parameters) != 1 {
+				continue
+			}
+			if path, err := entryURL(related, func(string) (string, bool) { return fieldText(element, name), true }); err == nil {
+				h.related = append(h.related, Link{name, path})
+			}
+		}
+	}
+	if linker, ok := element.(Linker); ok {
+		h.custom = linker.Links()
+	}
+	return h
+}
+
+// hypermediaResources finds the links for a resource or each element of a collection, reporting whether it was a
+// collection
+func hypermediaResources(i interface{}, r *http.Request) ([]hypermediaResource, bool, *RequestError) {
+	entry := defaultHandlerMutex.getHandler(requestTypeName(r))
+	entry.typeName = requestResourceName(r)
+
+	var elements []interface{}
+	if isSequence(i) {
+		err := forEach(r.Context(), i, func(element interface{}) error {
+			elements = append(elements, element)
+			return nil
+		})
+		if err != nil {
+			return nil, true, internalRequestError(err)
+		}
+	} else if v := reflect.ValueOf(i); (v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8) || v.Kind() == reflect.Array {
+		for idx := 0; idx < v.Len(); idx++ {
+			elements = append(elements, v.Index(idx).Interface())
+		}
+	} else {
+		return []hypermediaResource{newHypermediaResource(i, entry, r.URL.EscapedPath())}, false, nil
+	}
+
+	resources := make([]hypermediaResource, len(elements))
+	for idx, element := range elements {
+		resources[idx] = newHypermediaResource(element, entry, "")
+	}
+	return resources, true, nil
+}
+
+func writeHypermedia(w io.Writer, r *http.Request, document interface{}) *RequestError {
+	if err := writeJsonValue(r.Context(), document, w); err != nil {
+		return internalRequestError(err)
+	}
+	return nil
+}
+
+func halLink(href string) orderedObject {
+	return orderedObject{{"href", href}}
+}
+
+// halLinks groups links by relation, as HAL uses an array for a relation with several links
+func halLinks(links []Link) orderedObject {
+	var object orderedObject
+	indices := make(map[string]int)
+	for _, link := range links {
+		idx, ok := indices[link.Rel]
+		if !ok {
+			indices[link.Rel] = len(object)
+			object = append(object, objectMember{link.Rel, halLink(link.Href)})
+			continue
+		}
+		switch existing := object[idx].value.(type) {
+		case orderedObject:
+			object[idx].value = []orderedObject{existing, halLink(link.Href)}
+		case []orderedObject:
+			object[idx].value = append(existing, halLink(link.Href))
+		}
+	}
+	return object
+}
+
+func (h hypermediaResource) links(collection string) []Link {
+	var links []Link
+	if h.self != "" {
+		links = append(links, Link{"self", h.self})
+	}
+	if collection != "" {
+		links = append(links, Link{"collection", collection})
+	}
+	links = append(links, h.related...)
+	return append(links, h.custom...)
+}
+
+func (h hypermediaResource) hal(collection string) (interface{}, error) {
+	members, isObject, err := objectMembers(h.value)
+	if err != nil || !isObject {
+		return h.value, err
+	}
+	if links := h.links(collection); len(links) > 0 {
+		members = append(members, objectMember{"_links", halLinks(links)})
+	}
+	return members, nil
+}
+
+// halEncoder writes resources as application/hal+json, with a _links member. Collections are written as a resource
+// linking to the collection, with the elements embedded under the name of their type.
+func halEncoder(i interface{}, w io.Writer, r *http.Request) *RequestError {
+	resources, isCollection, err := hypermediaResources(i, r)
+	if err != nil {
+		return err
+	}
+	if !isCollection {
+		document, err := resources[0].hal("")
+		if err != nil {
+			return internalRequestError(err)
+		}
+		return writeHypermedia(w, r, document)
+	}
+
+	embedded := make([]interface{}, len(resources))
+	for idx, resource := range resources {
+		var err error
+		if embedded[idx], err = resource.hal(r.URL.EscapedPath()); err != nil {
+			return internalRequestError(err)
+		}
+	}
+	return writeHypermedia(w, r, orderedObject{
+		{"_links", halLinks([]Link{{"self", r.URL.EscapedPath()}})},
+		{"_embedded", orderedObject{{requestResourceName(r), embedded}}}})
+}
+
+func (h hypermediaResource) jsonAPI(typeName string) orderedObject {
+	object := orderedObject{{"type", typeName}}
+	if h.id != "" {
+		object = append(object, objectMember{"id", h.id})
+	}
+	object = append(object, objectMember{"attributes", h.value})
+	if len(h.related) > 0 {
+		var relationships orderedObject
+		for _, link := range h.related {
+			relationships = append(relationships, objectMember{link.Rel, orderedObject{{"links", orderedObject{{"related", link.Href}}}}})
+		}
+		object = append(object, objectMember{"relationships", relationships})
+	}
+	var links orderedObject
+	if h.self != "" {
+		links = append(links, objectMember{"self", h.self})
+	}
+	for _, link := range h.custom {
+		links = append(links, objectMember{link.Rel, link.Href})
+	}
+	if len(links) > 0 {
+		object = append(object, objectMember{"links", links})
+	}
+	return object
+}
+
+// jsonAPIEncoder writes resources as application/vnd.api+json documents. Fields identifying other registered
+// resources become relationships.
+func jsonAPIEncoder(i interface{}, w io.Writer, r *http.Request) *RequestError {
+	resources, isCollection, err := hypermediaResources(i, r)
+	if err != nil {
+		return err
+	}
+	typeName := requestResourceName(r)
+	var data interface{}
+	if isCollection {
+		elements := make([]orderedObject, len(resources))
+		for idx, resource := range resources {
+			elements[idx] = resource.jsonAPI(typeName)
+		}
+		data = elements
+	} else {
+		data = resources[0].jsonAPI(typeName)
+	}
+	return writeHypermedia(w, r, orderedObject{{"data", data}, {"links", orderedObject{{"self", r.URL.EscapedPath()}}}})
+}
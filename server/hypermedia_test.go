@@ -0,0 +1,104 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http/httptest"
+)
+
+type author struct {
+	Name string `json:"name"`
+}
+
+type review struct {
+	Title string `json:"title"`
+}
+
+func (r review) Links() []Link {
+	return []Link{{"alternate", "/reviews/" + r.Title + ".pdf"}}
+}
+
+func authorHandler(params PathParameters) (interface{}, *RequestError) {
+	name, _ := params.Get("name")
+	return author{name}, nil
+}
+
+var _ = Describe("hypermedia.go", func() {
+	BeforeEach(func() {
+		Resource(author{}, "/{name}", authorHandler)
+	})
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("rendering HAL", func() {
+		It("should link a resource to itself and related resources", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book/Neuromancer?fmt=hal")
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/hal+json"))
+			Expect(resp.Body.String()).To(Equal(`{"title":"Neuromancer","author":"Gibson, William","_links":{` +
+				`"self":{"href":"/book/Neuromancer"},"author":{"href":"/author/Gibson%2C%20William"}}}`))
+		})
+		It("should embed the elements of a collection", func() {
+			// Setup
+			Resource(book{}, "/{title}", func(_ PathParameters) (interface{}, *RequestError) {
+				return books[:1], nil
+			})
+			// Exercise
+			resp := get("http://localhost/book/all?fmt=hal")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`{"_links":{"self":{"href":"/book/all"}},"_embedded":{"book":[` +
+				`{"title":"Neuromancer","author":"Gibson, William","_links":{"self":{"href":"/book/Neuromancer"},` +
+				`"collection":{"href":"/book/all"},"author":{"href":"/author/Gibson%2C%20William"}}}]}}`))
+		})
+		It("should add the resource's own links", func() {
+			// Setup
+			SingletonResource(review{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return review{"Neuromancer"}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/review?fmt=hal")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`{"title":"Neuromancer","_links":{` +
+				`"self":{"href":"/review"},"alternate":{"href":"/reviews/Neuromancer.pdf"}}}`))
+		})
+		It("should be negotiated from the Accept header", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, acceptRequest("http://localhost/book/Neuromancer", "application/hal+json"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/hal+json"))
+		})
+	})
+	Describe("rendering JSON:API", func() {
+		It("should identify a resource and its relationships", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book/Neuromancer?fmt=jsonapi")
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/vnd.api+json"))
+			Expect(resp.Body.String()).To(Equal(`{"data":{"type":"book","id":"Neuromancer",` +
+				`"attributes":{"title":"Neuromancer","author":"Gibson, William"},` +
+				`"relationships":{"author":{"links":{"related":"/author/Gibson%2C%20William"}}},` +
+				`"links":{"self":"/book/Neuromancer"}},"links":{"self":"/book/Neuromancer"}}`))
+		})
+		It("should write a collection as an array of resources", func() {
+			// Setup
+			SingletonResource(author{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return []author{{"Gibson, William"}}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/author?fmt=jsonapi")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`{"data":[{"type":"author","attributes":{"name":"Gibson, William"}}],` +
+				`"links":{"self":"/author"}}`))
+		})
+	})
+})
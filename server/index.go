@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ResourceDescription describes a registered resource in the index served at the mount root
+type ResourceDescription struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Methods []string `json:"methods"`
+	Formats []string `json:"formats"`
+	Schema  string   `json:"schema,omitempty"`
+}
+
+// ResourceIndex lists the registered resources, so clients can discover them
+type ResourceIndex struct {
+	Resources []ResourceDescription `json:"resources"`
+}
+
+// builtinTemplates render the framework's own resources when the application doesn't supply a template for them
+var builtinTemplates = map[string]string{
+	"server.ResourceIndex.html": `<html><body><ul>{{range .Resources}}<li>{{.Name}}: {{.URL}} ({{join .Methods ", "}}; {{join .Formats ", "}})</li>{{end}}</ul></body></html>`,
+	"server.ResourceIndex.text": `{{range .Resources}}{{.Name}} {{.URL}} {{join .Methods ","}} {{join .Formats ","}}
+{{end}}`}
+
+func (mutex *handlerMutex) entries() []mutexEntry {
+	mutex.mutex.RLock()
+	defer mutex.mutex.RUnlock()
+
+	entries := make([]mutexEntry, 0, len(mutex.handlers))
+	for _, entry := range mutex.handlers {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].typeName < entries[j].typeName })
+	return entries
+}
+
+// entryPattern is the URL pattern of a registered resource e.g. /book/{title}
+func entryPattern(entry mutexEntry) string {
+	pattern := getMountPrefix() + "/" + entry.typeName
+	for _, parameter := range entry.parameters {
+		pattern += "/{" + parameter + "}"
+	}
+	return pattern
+}
+
+// entryFormats lists the formats a resource is available in, from the registered encoders and the templates for it
+func entryFormats(entry mutexEntry) []string {
+	formats := encoderFormats()
+	if entry.resourceType != nil {
+		prefix := entry.resourceType.String() + "."
+		templates, _ := filepath.Glob(prefix + "*")
+		for _, template := range templates {
+			if format := strings.TrimPrefix(template, prefix); !contains(formats, format) {
+				formats = append(formats, format)
+			}
+		}
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func describeResources() ResourceIndex {
+	index := ResourceIndex{Resources: []ResourceDescription{}}
+	for _, entry := range defaultHandlerMutex.entries() {
+		index.Resources = append(index.Resources, ResourceDescription{
+			Name:    entry.typeName,
+			URL:     entryPattern(entry),
+			Methods: allowedMethods(entry),
+			Formats: entryFormats(entry),
+			Schema:  schemaURL(entry)})
+	}
+	return index
+}
+
+func indexHandler(_ PathParameters) (interface{}, *RequestError) {
+	return describeResources(), nil
+}
+
+// isMountRoot reports whether a request is for the index, rather than a resource
+func isMountRoot(r *http.Request) bool {
+	path, ok := mountedPath(r.URL.Path)
+	return ok && path == "/"
+}
+
+// indexEntry serves the index like any other resource
+var indexEntry = mutexEntry{handler: indexHandler}
+
+// builtinEntry returns the framework's own resource for a request, if there is one. Applications may replace them by
+// registering resources of the same name.
+func builtinEntry(r *http.Request, typeName string) mutexEntry {
+	switch {
+	case typeName == "" && isMountRoot(r):
+		return indexEntry
+	case typeName == schemasTypeName:
+		return schemasEntry
+	}
+	return mutexEntry{}
+}
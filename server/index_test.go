@@ -0,0 +1,70 @@
+package server_test
+
+import (
+	"github.com/cleggatt/gowest/internal/storetest"
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"net/http/httptest"
+)
+
+var _ = Describe("index.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+		SetMountPrefix("")
+	})
+	Describe("requesting the mount root", func() {
+		BeforeEach(func() {
+			Resource(book{}, "/{title}", serverBookHandler, Put(storetest.Store[book]{}.Put))
+			SingletonResource(author{}, authorHandler)
+		})
+		It("should list the registered resources", func() {
+			// Exercise
+			resp := get("http://localhost/?fmt=json")
+			// Verify
+			Expect(resp.Code).To(Equal(200))
+			var index ResourceIndex
+			Expect(json.Unmarshal(resp.Body.Bytes(), &index)).To(Succeed())
+			Expect(index.Resources).To(HaveLen(2))
+			Expect(index.Resources[0].Name).To(Equal("author"))
+			Expect(index.Resources[0].URL).To(Equal("/author"))
+			Expect(index.Resources[0].Methods).To(Equal([]string{"GET", "HEAD"}))
+			Expect(index.Resources[0].Formats).To(ContainElements("hal", "json", "json-seq", "jsonapi", "ndjson"))
+			Expect(index.Resources[0].Formats).NotTo(ContainElement("html"))
+			Expect(index.Resources[1].Name).To(Equal("book"))
+			Expect(index.Resources[1].URL).To(Equal("/book/{title}"))
+			Expect(index.Resources[1].Methods).To(Equal([]string{"GET", "HEAD", "PUT"}))
+			Expect(index.Resources[1].Formats).To(ContainElements("csv", "html", "json", "text"))
+		})
+		It("should be below the mount prefix", func() {
+			// Setup
+			SetMountPrefix("/api")
+			// Exercise
+			resp := get("http://localhost/api?fmt=json")
+			// Verify
+			Expect(resp.Body.String()).To(ContainSubstring(`"url":"/api/book/{title}"`))
+		})
+		It("should be negotiated from the Accept header", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, acceptRequest("http://localhost/", "text/html"))
+			// Verify
+			Expect(resp.Code).To(Equal(200))
+			Expect(resp.Body.String()).To(ContainSubstring(`<li>book: /book/{title} (GET, HEAD, PUT; csv, hal, html`))
+		})
+		It("should be rendered as text", func() {
+			// Exercise
+			resp := get("http://localhost/?fmt=text")
+			// Verify
+			Expect(resp.Body.String()).To(HavePrefix("author /author GET,HEAD hal,json,"))
+		})
+		It("should not be modifiable", func() {
+			// Exercise
+			resp := modifyRequest("DELETE", "http://localhost/", "", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(405))
+		})
+	})
+})
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds (in seconds) of the latency histogram buckets, matching the Prometheus client defaults
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricLabels struct {
+	resourceType string
+	format       string
+	status       int
+}
+
+func (l metricLabels) String() string {
+	return fmt.Sprintf("type=\"%s\",format=\"%s\",status=\"%d\"", escapeLabel(l.resourceType), escapeLabel(l.format), l.status)
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram() *histogram { return &histogram{counts: make([]uint64, len(latencyBuckets))} }
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	for idx, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[idx]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+type metricsRegistry struct {
+	mutex    sync.Mutex
+	requests map[metricLabels]uint64
+	handlers map[metricLabels]*histogram
+	renders  map[metricLabels]*histogram
+	timeouts map[string]uint64
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		requests: make(map[metricLabels]uint64),
+		handlers: make(map[metricLabels]*histogram),
+		renders:  make(map[metricLabels]*histogram),
+		timeouts: make(map[string]uint64)}
+}
+
+var defaultMetrics = newMetricsRegistry()
+
+func ClearMetrics() {
+	// FIXME This is not threadsafe, but is just used for tests ATM
+	defaultMetrics = newMetricsRegistry()
+}
+
+func observeHistogram(histograms map[metricLabels]*histogram, labels metricLabels, d time.Duration) {
+	h, ok := histograms[labels]
+	if !ok {
+		h = newHistogram()
+		histograms[labels] = h
+	}
+	h.observe(d)
+}
+
+// observe records a completed request. The render time is only recorded if a representation was attempted.
+func (m *metricsRegistry) observe(labels metricLabels, timing *requestTiming) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[labels]++
+	observeHistogram(m.handlers, labels, timing.handler)
+	if timing.rendered {
+		observeHistogram(m.renders, labels, timing.render)
+	}
+}
+
+func (m *metricsRegistry) countTimeout(typeName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.timeouts[typeName]++
+}
+
+// metricLabelsFor builds the labels for a request. Unregistered types and unsupported formats are collapsed to an
+// empty label, so that arbitrary client input can't create an unbounded number of series.
+func metricLabelsFor(r *http.Request, err *RequestError) metricLabels {
+	labels := metricLabels{status: http.StatusOK}
+	if err != nil {
+		labels.status = err.Code
+	}
+	entry := defaultHandlerMutex.getHandler(requestTypeName(r))
+	if entry.handler != nil {
+		labels.resourceType = entry.typeName
+	}
+	if format := requestFormat(r); isSupportedFormat(entry, format) {
+		labels.format = format
+	}
+	return labels
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
+
+func sortedLabels(keys []metricLabels) []metricLabels {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+func writeCounter(w io.Writer, name string, help string, counters map[metricLabels]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]metricLabels, 0, len(counters))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+	for _, k := range sortedLabels(keys) {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, k, counters[k])
+	}
+}
+
+func writeTypeCounter(w io.Writer, name string, help string, counters map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(counters))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{type=\"%s\"} %d\n", name, escapeLabel(k), counters[k])
+	}
+}
+
+func writeHistogram(w io.Writer, name string, help string, histograms map[metricLabels]*histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]metricLabels, 0, len(histograms))
+	for k := range histograms {
+		keys = append(keys, k)
+	}
+	for _, k := range sortedLabels(keys) {
+		h := histograms[k]
+		for idx, bound := range latencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, k, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[idx])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, k, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, k, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, k, h.count)
+	}
+}
+
+func (m *metricsRegistry) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	writeCounter(w, "gowest_requests_total", "Total number of requests handled.", m.requests)
+	writeHistogram(w, "gowest_handler_duration_seconds", "Time spent locating and executing resource handlers.", m.handlers)
+	writeHistogram(w, "gowest_render_duration_seconds", "Time spent rendering resource representations.", m.renders)
+	writeTypeCounter(w, "gowest_timeouts_total", "Total number of handlers that exceeded their timeout.", m.timeouts)
+}
+
+// MetricsHandler writes the collected metrics in the Prometheus text exposition format. It is not registered by
+// default; use http.HandleFunc("/metrics", MetricsHandler) to expose it.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	defaultMetrics.write(w)
+}
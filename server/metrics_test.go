@@ -0,0 +1,93 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+)
+
+func metricsBody() string {
+	resp := httptest.NewRecorder()
+	MetricsHandler(resp, request("http://localhost:8080/metrics"))
+	return resp.Body.String()
+}
+
+var _ = Describe("metrics.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+		ClearMetrics()
+	})
+	Describe("exposing metrics", func() {
+		It("should use the Prometheus text format", func() {
+			// Exercise
+			resp := httptest.NewRecorder()
+			MetricsHandler(resp, request("http://localhost:8080/metrics"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+			Expect(resp.Body.String()).To(ContainSubstring("# TYPE gowest_requests_total counter\n"))
+			Expect(resp.Body.String()).To(ContainSubstring("# TYPE gowest_handler_duration_seconds histogram\n"))
+			Expect(resp.Body.String()).To(ContainSubstring("# TYPE gowest_render_duration_seconds histogram\n"))
+		})
+	})
+	Describe("recording requests", func() {
+		It("should count successful requests by type, format and status", func() {
+			// Setup
+			SingletonResource(book{}, getSingleResourceHandler)
+			// Exercise
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/book?fmt=json"))
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			body := metricsBody()
+			Expect(body).To(ContainSubstring("gowest_requests_total{type=\"book\",format=\"json\",status=\"200\"} 2\n"))
+			Expect(body).To(ContainSubstring("gowest_handler_duration_seconds_count{type=\"book\",format=\"json\",status=\"200\"} 2\n"))
+			Expect(body).To(ContainSubstring("gowest_render_duration_seconds_count{type=\"book\",format=\"json\",status=\"200\"} 2\n"))
+			Expect(body).To(ContainSubstring("gowest_render_duration_seconds_bucket{type=\"book\",format=\"json\",status=\"200\",le=\"+Inf\"} 2\n"))
+		})
+		It("should not label requests for unregistered types", func() {
+			// Exercise
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/Missing?fmt=json"))
+			// Verify
+			Expect(metricsBody()).To(ContainSubstring("gowest_requests_total{type=\"\",format=\"\",status=\"404\"} 1\n"))
+		})
+		It("should count unsupported formats", func() {
+			// Setup
+			SingletonResource(book{}, getSingleResourceHandler)
+			// Exercise
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/book?fmt=missing"))
+			// Verify
+			body := metricsBody()
+			Expect(body).To(ContainSubstring("gowest_requests_total{type=\"book\",format=\"\",status=\"406\"} 1\n"))
+			Expect(body).To(ContainSubstring("gowest_handler_duration_seconds_count{type=\"book\",format=\"\",status=\"406\"} 1\n"))
+		})
+		It("should not label requests with formats that aren't supported, whatever their status", func() {
+			// Setup
+			SingletonResource(book{}, getSingleResourceHandler)
+			// Exercise
+			MainHandler(httptest.NewRecorder(), &http.Request{Method: "DELETE", URL: request("http://localhost:8080/book?fmt=junk1").URL})
+			MainHandler(httptest.NewRecorder(), &http.Request{Method: "DELETE", URL: request("http://localhost:8080/book?fmt=junk2").URL})
+			// Verify
+			body := metricsBody()
+			Expect(body).To(ContainSubstring("gowest_requests_total{type=\"book\",format=\"\",status=\"405\"} 2\n"))
+			Expect(body).NotTo(ContainSubstring("junk"))
+		})
+		It("should label requests with formats rendered by a template", func() {
+			// Setup
+			SingletonResource(book{}, errorResourceHandler)
+			// Exercise
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/book?fmt=html"))
+			// Verify
+			Expect(metricsBody()).To(ContainSubstring("gowest_requests_total{type=\"book\",format=\"html\",status=\"400\"} 1\n"))
+		})
+		It("should count handler errors", func() {
+			// Setup
+			SingletonResource(book{}, errorResourceHandler)
+			// Exercise
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(metricsBody()).To(ContainSubstring("gowest_requests_total{type=\"book\",format=\"json\",status=\"400\"} 1\n"))
+		})
+	})
+})
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// PutHandler replaces the resource identified by params with one decoded from the request body. It returns the
+// resource as stored, or nil for an empty response.
+type PutHandler func(params PathParameters, resource interface{}) (interface{}, *RequestError)
+
+// DeleteHandler removes the resource identified by params
+type DeleteHandler func(params PathParameters) *RequestError
+
+// Put allows the resource to be replaced with a PUT request
+func Put(handler PutHandler) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.putHandler = handler
+	}
+}
+
+// Delete allows the resource to be removed with a DELETE request
+func Delete(handler DeleteHandler) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.deleteHandler = handler
+	}
+}
+
+// RequirePreconditions rejects PUT and DELETE requests without an If-Match header, so that clients can't overwrite
+// changes they haven't seen
+func RequirePreconditions() ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.requirePreconditions = true
+	}
+}
+
+func allowedMethods(entry mutexEntry) []string {
+	methods := []string{"GET", "HEAD"}
+	if entry.putHandler != nil {
+		methods = append(methods, "PUT")
+	}
+	if entry.deleteHandler != nil {
+		methods = append(methods, "DELETE")
+	}
+	return methods
+}
+
+func methodNotAllowed(r *http.Request, entry mutexEntry) *RequestError {
+	return &RequestError{
+		Error:   fmt.Errorf("%s is not supported by %s", requestMethod(r), entry.typeName),
+		Message: fmt.Sprintf("'%s' is not a supported method", requestMethod(r)),
+		Code:    http.StatusMethodNotAllowed}
+}
+
+func decodeBody(r *http.Request, t reflect.Type) (interface{}, *RequestError) {
+	// TODO Support formats other than JSON
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+			return nil, &RequestError{Error: fmt.Errorf("Unsupported content type [%s]", contentType), Message: fmt.Sprintf("'%s' is not a supported content type", contentType), Code: http.StatusUnsupportedMediaType}
+		}
+	}
+	if r.Body == nil {
+		return nil, &RequestError{Error: fmt.Errorf("Missing request body"), Message: "A request body is required", Code: http.StatusBadRequest}
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logf(r.Context(), "Unable to read request body: %v", err)
+		return nil, &RequestError{Error: err, Message: "The request body is invalid", Code: http.StatusBadRequest}
+	}
+
+	// The types in the body are checked before decoding, so the client hears about every problem rather than just the
+	// first one encoding/json finds
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		logf(r.Context(), "Unable to decode request body: %v", err)
+		return nil, &RequestError{Error: err, Message: "The request body is invalid", Code: http.StatusBadRequest}
+	}
+	typeViolations := schemaFor(t).checkTypes(generic, "")
+
+	// encoding/json decodes as much as it can around values of the wrong type, so the rules can still be checked
+	value := reflect.New(t)
+	if err := json.Unmarshal(body, value.Interface()); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) || len(typeViolations) == 0 {
+			logf(r.Context(), "Unable to decode request body: %v", err)
+			return nil, &RequestError{Error: err, Message: "The request body is invalid", Code: http.StatusBadRequest}
+		}
+	}
+	if violations := mergeViolations(typeViolations, validateValue(value, generic, "")); len(violations) > 0 {
+		logf(r.Context(), "Request body has %d violations", len(violations))
+		return nil, &RequestError{Error: fmt.Errorf("Request body has %d violations", len(violations)), Message: "The request body is invalid", Code: http.StatusUnprocessableEntity, Violations: violations}
+	}
+	return value.Elem().Interface(), nil
+}
+
+// currentETag returns the tag a GET in the same format would have returned, or "" if the resource doesn't exist
+func currentETag(r *http.Request, entry mutexEntry, params parameterMap) (string, *RequestError) {
+	current, err := callHandler(r.Context(), entry, params, entry.handler)
+	if err != nil {
+		if err.Code == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	if v := resourceValidators(current, requestFormat(r)); v.etag != "" {
+		return v.etag, nil
+	}
+	bytes, err := getBytes(current, r)
+	if err != nil {
+		return "", err
+	}
+	return bytesETag(bytes), nil
+}
+
+// etagMatchesStrongly performs the strong comparison required for If-Match
+func etagMatchesStrongly(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		// A compressed variant has the same content, so clients may use its tag
+		if candidate == "*" || (identityETag(candidate) == identityETag(etag) && !strings.HasPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPreconditions(r *http.Request, entry mutexEntry, params parameterMap) *RequestError {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if entry.requirePreconditions {
+			return &RequestError{Error: fmt.Errorf("Missing If-Match for %s", entry.typeName), Message: "An If-Match header is required", Code: http.StatusPreconditionRequired}
+		}
+		return nil
+	}
+	etag, err := currentETag(r, entry, params)
+	if err != nil {
+		return err
+	}
+	if !etagMatchesStrongly(header, etag) {
+		logf(r.Context(), "If-Match [%s] does not match current ETag [%s]", header, etag)
+		return &RequestError{Error: fmt.Errorf("If-Match [%s] does not match [%s]", header, etag), Message: "The resource has been modified", Code: http.StatusPreconditionFailed}
+	}
+	return nil
+}
+
+// ModifyResource handles PUT and DELETE requests. It returns the resource to send to the client, which is nil if the
+// response should be empty.
+func ModifyResource(r *http.Request) (interface{}, *RequestError) {
+	entry, params, err := resolveResource(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler GetHandler
+	switch {
+	case r.Method == "PUT" && entry.putHandler != nil:
+		resource, err := decodeBody(r, entry.resourceType)
+		if err != nil {
+			return nil, err
+		}
+		handler = func(params PathParameters) (interface{}, *RequestError) {
+			return entry.putHandler(params, resource)
+		}
+	case r.Method == "DELETE" && entry.deleteHandler != nil:
+		handler = func(params PathParameters) (interface{}, *RequestError) {
+			return nil, entry.deleteHandler(params)
+		}
+	default:
+		return nil, methodNotAllowed(r, entry)
+	}
+
+	// The format is checked first, so a change isn't made only for its response to be refused
+	if format := requestFormat(r); !isSupportedFormat(entry, format) {
+		return nil, &RequestError{Error: fmt.Errorf("No encoder or template for [%s] in [%s]", entry.typeName, format), Message: fmt.Sprintf("'%s' is not a supported format", format), Code: http.StatusNotAcceptable}
+	}
+
+	// TODO The check and the write aren't atomic, so handlers needing a strict guarantee must still check versions
+	if err := checkPreconditions(r, entry, params); err != nil {
+		return nil, err
+	}
+
+	ctx, handlerSpan := startSpan(r.Context(), "gowest.handler")
+	handlerSpan.SetAttribute("gowest.type", entry.typeName)
+	resource, err := callHandler(ctx, entry, params, handler)
+	endSpan(handlerSpan, err)
+	if err == nil {
+		defaultResponseCache.invalidate(entry.typeName)
+	}
+	return resource, err
+}
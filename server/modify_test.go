@@ -0,0 +1,157 @@
+package server_test
+
+import (
+	"github.com/cleggatt/gowest/internal/storetest"
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func modifyRequest(method string, target string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp := httptest.NewRecorder()
+	MainHandler(resp, req)
+	return resp
+}
+
+var _ = Describe("modify.go", func() {
+	var store storetest.Store[book]
+	BeforeEach(func() {
+		store = storetest.Store[book]{"Neuromancer": book{"Neuromancer", "Gibson, William"}}
+	})
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("PUTting a resource", func() {
+		It("should pass the decoded resource to the handler", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Count_Zero?fmt=json", "{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}"))
+			Expect(store["Count_Zero"]).To(Equal(book{"Count Zero", "Gibson, William"}))
+		})
+		It("should respond with JSON if no format is requested", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Count_Zero", "{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}", map[string]string{"Content-Type": "application/json"})
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Content-Type")).To(HavePrefix("application/json"))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}"))
+		})
+		It("should return a 406 error without changing the resource for an unsupported format", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Count_Zero?fmt=missing", "{\"title\":\"Count Zero\",\"author\":\"Gibson, William\"}", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNotAcceptable))
+			Expect(store).NotTo(HaveKey("Count_Zero"))
+		})
+		It("should return a 400 error for an invalid body", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Neuromancer?fmt=json", "{", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		})
+		It("should return a 415 error for an unsupported content type", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Neuromancer?fmt=json", "title", map[string]string{"Content-Type": "text/plain"})
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusUnsupportedMediaType))
+		})
+	})
+	Describe("DELETEing a resource", func() {
+		It("should return an empty response", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Delete(store.Delete))
+			// Exercise
+			resp := modifyRequest("DELETE", "/book/Neuromancer?fmt=json", "", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNoContent))
+			Expect(resp.Body.String()).To(Equal(""))
+			Expect(store).To(BeEmpty())
+		})
+	})
+	Describe("using an unsupported method", func() {
+		It("should return a 405 error listing the allowed methods", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Delete(store.Delete))
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Neuromancer?fmt=json", "{}", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(resp.Header().Get("Allow")).To(Equal("GET, HEAD, DELETE"))
+		})
+	})
+	Describe("modifying with preconditions", func() {
+		var etag string
+		BeforeEach(func() {
+			Resource(book{}, "/{title}", store.Get, Put(store.Put), Delete(store.Delete))
+			etag = modifyRequest("GET", "/book/Neuromancer?fmt=json", "", nil).Header().Get("ETag")
+		})
+		It("should allow the change if the ETag matches", func() {
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Neuromancer?fmt=json", "{\"title\":\"Neuromancer\",\"author\":\"Gibson, W.\"}", map[string]string{"If-Match": etag})
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(store["Neuromancer"].Author).To(Equal("Gibson, W."))
+			Expect(resp.Header().Get("ETag")).ToNot(Equal(etag))
+		})
+		It("should compare the ETag of the JSON representation if no format is requested", func() {
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Neuromancer", "{\"title\":\"Neuromancer\",\"author\":\"Gibson, W.\"}", map[string]string{"If-Match": etag})
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(store["Neuromancer"].Author).To(Equal("Gibson, W."))
+		})
+		It("should return a 412 error if the ETag doesn't match", func() {
+			// Setup
+			modifyRequest("PUT", "/book/Neuromancer?fmt=json", "{\"title\":\"Neuromancer\",\"author\":\"Gibson, W.\"}", nil)
+			// Exercise
+			resp := modifyRequest("DELETE", "/book/Neuromancer?fmt=json", "", map[string]string{"If-Match": etag})
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(store).To(HaveKey("Neuromancer"))
+		})
+		It("should not match a weak ETag", func() {
+			// Exercise
+			resp := modifyRequest("DELETE", "/book/Neuromancer?fmt=json", "", map[string]string{"If-Match": "W/" + etag})
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusPreconditionFailed))
+		})
+		It("should return a 412 error for a missing resource", func() {
+			// Exercise
+			resp := modifyRequest("PUT", "/book/Count_Zero?fmt=json", "{}", map[string]string{"If-Match": "*"})
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(store).ToNot(HaveKey("Count_Zero"))
+		})
+	})
+	Describe("modifying a resource requiring preconditions", func() {
+		It("should return a 428 error without If-Match", func() {
+			// Setup
+			Resource(book{}, "/{title}", store.Get, Delete(store.Delete), RequirePreconditions())
+			// Exercise
+			resp := modifyRequest("DELETE", "/book/Neuromancer?fmt=json", "", nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusPreconditionRequired))
+			Expect(store).To(HaveKey("Neuromancer"))
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cleggatt/gowest/api"
+)
+
+// ResourceNamer is implemented by types which choose the name they're registered under, rather than having one
+// derived from the name of the type
+type ResourceNamer = api.ResourceNamer
+
+// NamingPolicy derives the name of a resource, which is the first element of its URLs, from the name of its type
+type NamingPolicy = api.NamingPolicy
+
+var (
+	// TypeName uses the name of the type as it is e.g. BookReview. This is the default.
+	TypeName = api.TypeName
+	// LowerCase uses the name of the type in lower case e.g. bookreview
+	LowerCase = api.LowerCase
+	// KebabCase separates the words in the name of the type with hyphens e.g. book-review
+	KebabCase = api.KebabCase
+)
+
+// Plural uses the plural of the name given by another policy e.g. Plural(KebabCase) gives book-reviews
+func Plural(policy NamingPolicy) NamingPolicy {
+	return api.Plural(policy)
+}
+
+// SetNamingPolicy sets how resources registered after it's called are named, unless their types implement
+// ResourceNamer or they're registered with the Named option
+func SetNamingPolicy(policy NamingPolicy) {
+	api.SetNamingPolicy(policy)
+}
+
+// Named registers a resource under the given name, rather than one derived from its type
+func Named(name string) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.typeName = name
+	}
+}
+
+// nameKey is how names are compared, so that /Book and /book are the same resource
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// checkName reports why a resource can't be registered under its name, if it can't
+func (mutex *handlerMutex) checkName(entry mutexEntry) error {
+	if entry.typeName == "" {
+		return fmt.Errorf("[%v] has no name; use the Named option or implement ResourceNamer", entry.resourceType)
+	}
+	if url.PathEscape(entry.typeName) != entry.typeName {
+		return fmt.Errorf("[%s] can't be used as the name of a resource, as it isn't a valid URL path element", entry.typeName)
+	}
+	// Registering a type again replaces its handlers, but two types can't share a name
+	if existing, ok := mutex.handlers[nameKey(entry.typeName)]; ok && existing.resourceType != entry.resourceType {
+		return fmt.Errorf("[%s] is already registered for [%v] as [%s]", entry.typeName, existing.resourceType, existing.typeName)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+)
+
+type BookReview struct {
+	Rating int `json:"rating"`
+}
+
+type HTTPServer struct{}
+
+type Category struct{}
+
+type Box struct{}
+
+type Key struct{}
+
+type publisher struct {
+	Name string `json:"name"`
+}
+
+func (publisher) ResourceName() string { return "imprint" }
+
+func getBookReview(_ PathParameters) (interface{}, *RequestError) {
+	return BookReview{5}, nil
+}
+
+var _ = Describe("naming.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+		SetNamingPolicy(TypeName)
+	})
+	Describe("deriving names from types", func() {
+		It("should use the name of the type by default", func() {
+			Expect(ResourceName(BookReview{})).To(Equal("BookReview"))
+		})
+		It("should apply the naming policy", func() {
+			// Exercise
+			SetNamingPolicy(LowerCase)
+			lower := ResourceName(BookReview{})
+			SetNamingPolicy(KebabCase)
+			kebab := []string{ResourceName(BookReview{}), ResourceName(HTTPServer{})}
+			SetNamingPolicy(Plural(KebabCase))
+			plural := []string{ResourceName(BookReview{}), ResourceName(Category{}), ResourceName(Box{}), ResourceName(Key{})}
+			// Verify
+			Expect(lower).To(Equal("bookreview"))
+			Expect(kebab).To(Equal([]string{"book-review", "http-server"}))
+			Expect(plural).To(Equal([]string{"book-reviews", "categories", "boxes", "keys"}))
+		})
+		It("should prefer the name a type chooses for itself", func() {
+			// Setup
+			SetNamingPolicy(Plural(LowerCase))
+			// Exercise
+			name := ResourceName(&publisher{})
+			// Verify
+			Expect(name).To(Equal("imprint"))
+		})
+	})
+	Describe("serving named resources", func() {
+		It("should serve a resource under the name the policy gives it", func() {
+			// Setup
+			SetNamingPolicy(Plural(KebabCase))
+			SingletonResource(BookReview{}, getBookReview)
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, request("http://localhost:8080/book-reviews?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("{\"rating\":5}"))
+		})
+		It("should serve a resource under an explicit name", func() {
+			// Setup
+			SingletonResource(BookReview{}, getBookReview, Named("critique"))
+			// Exercise
+			url, err := URLFor(BookReview{}, nil)
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(url).To(Equal("/critique"))
+		})
+		It("should match names regardless of case", func() {
+			// Setup
+			SingletonResource(BookReview{}, getBookReview)
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, request("http://localhost:8080/bookreview?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+		})
+	})
+	Describe("detecting collisions", func() {
+		It("should return an error when two types share a name", func() {
+			// Setup
+			SingletonResource(BookReview{}, getBookReview)
+			// Exercise
+			err := SingletonResource(HTTPServer{}, getBookReview, Named("bookReview"))
+			// Verify
+			Expect(err).To(MatchError("[bookReview] is already registered for [server_test.BookReview] as [BookReview]"))
+		})
+		It("should replace the handlers of a type registered again", func() {
+			// Setup
+			SingletonResource(BookReview{}, serverBookHandler)
+			SingletonResource(BookReview{}, getBookReview)
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, request("http://localhost:8080/BookReview?fmt=json"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal("{\"rating\":5}"))
+		})
+		It("should return an error when a type has no name", func() {
+			// Exercise
+			err := SingletonResource(struct{}{}, getBookReview)
+			// Verify
+			Expect(err).To(MatchError("[struct {}] has no name; use the Named option or implement ResourceNamer"))
+		})
+		It("should accept a type without a name given an explicit one", func() {
+			// Exercise
+			err := SingletonResource(struct{}{}, getBookReview, Named("anonymous"))
+			// Verify
+			Expect(err).To(BeNil())
+		})
+		It("should return an error for a name which can't be used in a URL", func() {
+			// Exercise
+			err := SingletonResource(BookReview{}, getBookReview, Named("book/review"))
+			// Verify
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
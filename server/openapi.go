@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIDocument is an OpenAPI 3 description of the registered resources
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents          `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIPathItem holds the operations on a path, by lower case method
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+const errorSchemaName = "Error"
+
+func schemaRef(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func errorResponses(codes ...int) map[string]OpenAPIResponse {
+	responses := make(map[string]OpenAPIResponse, len(codes))
+	for _, code := range codes {
+		responses[strconv.Itoa(code)] = OpenAPIResponse{
+			Description: http.StatusText(code),
+			Content:     map[string]OpenAPIMediaType{"application/json": {schemaRef(errorSchemaName)}}}
+	}
+	return responses
+}
+
+// representationContent lists the media types a resource is available in, describing its JSON representation as a
+// single resource or a collection of them
+func representationContent(entry mutexEntry, collection bool) map[string]OpenAPIMediaType {
+	content := make(map[string]OpenAPIMediaType)
+	for _, format := range entryFormats(entry) {
+		mediaType := strings.Split(contentType(format, nil), ";")[0]
+		if _, ok := content[mediaType]; !ok {
+			content[mediaType] = OpenAPIMediaType{}
+		}
+	}
+	if collection {
+		content["application/json"] = OpenAPIMediaType{&Schema{Type: "array", Items: schemaRef(entry.typeName)}}
+	} else {
+		content["application/json"] = OpenAPIMediaType{schemaRef(entry.typeName)}
+	}
+	return content
+}
+
+func queryParameter(name string, schema *Schema) OpenAPIParameter {
+	return OpenAPIParameter{Name: name, In: "query", Schema: schema}
+}
+
+func getOperation(entry mutexEntry, pathParameters []OpenAPIParameter) *OpenAPIOperation {
+	op := &OpenAPIOperation{OperationID: "get" + entry.typeName, Parameters: pathParameters}
+	stringSchema := &Schema{Type: "string"}
+	op.Parameters = append(op.Parameters, queryParameter("fmt", stringSchema), queryParameter("fields", stringSchema),
+		queryParameter("expand", stringSchema))
+	if entry.pagination != nil {
+		op.Parameters = append(op.Parameters, queryParameter("page", &Schema{Type: "integer"}),
+			queryParameter("limit", &Schema{Type: "integer"}), queryParameter("cursor", stringSchema))
+	}
+	if entry.sortable != nil {
+		op.Parameters = append(op.Parameters, queryParameter("sort", stringSchema))
+	}
+	for _, field := range entry.filterable {
+		op.Parameters = append(op.Parameters, queryParameter(field, stringSchema))
+	}
+
+	op.Responses = errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusNotAcceptable,
+		http.StatusInternalServerError, http.StatusServiceUnavailable)
+	op.Responses["200"] = OpenAPIResponse{Description: http.StatusText(http.StatusOK), Content: representationContent(entry, entry.collection)}
+	op.Responses["304"] = OpenAPIResponse{Description: http.StatusText(http.StatusNotModified)}
+	return op
+}
+
+func modifyResponses(entry mutexEntry, codes ...int) map[string]OpenAPIResponse {
+	codes = append(codes, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusInternalServerError,
+		http.StatusServiceUnavailable)
+	if entry.requirePreconditions {
+		codes = append(codes, http.StatusPreconditionRequired)
+	}
+	responses := errorResponses(codes...)
+	responses["204"] = OpenAPIResponse{Description: http.StatusText(http.StatusNoContent)}
+	return responses
+}
+
+func putOperation(entry mutexEntry, pathParameters []OpenAPIParameter) *OpenAPIOperation {
+	op := &OpenAPIOperation{OperationID: "put" + entry.typeName, Parameters: pathParameters}
+	op.RequestBody = &OpenAPIRequestBody{Required: true,
+		Content: map[string]OpenAPIMediaType{"application/json": {schemaRef(entry.typeName)}}}
+	op.Responses = modifyResponses(entry, http.StatusBadRequest, http.StatusUnsupportedMediaType,
+		http.StatusUnprocessableEntity)
+	op.Responses["200"] = OpenAPIResponse{Description: http.StatusText(http.StatusOK), Content: representationContent(entry, false)}
+	return op
+}
+
+func deleteOperation(entry mutexEntry, pathParameters []OpenAPIParameter) *OpenAPIOperation {
+	op := &OpenAPIOperation{OperationID: "delete" + entry.typeName, Parameters: pathParameters}
+	op.Responses = modifyResponses(entry)
+	return op
+}
+
+// OpenAPI describes the registered resources as an OpenAPI 3 document. Paths come from the patterns the resources were
+// registered with, and schemas from their types.
+func OpenAPI(title string, version string) OpenAPIDocument {
+	doc := OpenAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       OpenAPIInfo{title, version},
+		Paths:      make(map[string]OpenAPIPathItem),
+		Components: OpenAPIComponents{Schemas: map[string]*Schema{errorSchemaName: schemaFor(reflect.TypeOf(ErrorRepresentation{}))}}}
+	if prefix := getMountPrefix(); prefix != "" {
+		doc.Servers = []OpenAPIServer{{prefix}}
+	}
+
+	for _, entry := range defaultHandlerMutex.entries() {
+		pathParameters := make([]OpenAPIParameter, len(entry.parameters))
+		for idx, parameter := range entry.parameters {
+			pathParameters[idx] = OpenAPIParameter{Name: parameter, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		}
+		item := OpenAPIPathItem{"get": getOperation(entry, pathParameters)}
+		if entry.putHandler != nil {
+			item["put"] = putOperation(entry, pathParameters)
+		}
+		if entry.deleteHandler != nil {
+			item["delete"] = deleteOperation(entry, pathParameters)
+		}
+		doc.Paths[strings.TrimPrefix(entryPattern(entry), getMountPrefix())] = item
+		if entry.resourceType != nil {
+			doc.Components.Schemas[entry.typeName] = schemaFor(entry.resourceType)
+		}
+	}
+	return doc
+}
+
+// OpenAPIHandler serves the OpenAPI document for the registered resources. Like MetricsHandler, it is not registered
+// by default; use http.Handle("/openapi.json", OpenAPIHandler("Books", "1.0")) to expose it.
+func OpenAPIHandler(title string, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(OpenAPI(title, version))
+		if err != nil {
+			logf(r.Context(), "Unable to marshall OpenAPI document: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(b)
+	}
+}
@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"github.com/cleggatt/gowest/internal/storetest"
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"net/http/httptest"
+	"time"
+)
+
+type catalogue struct {
+	Title     string    `json:"title"`
+	Pages     int       `json:"pages"`
+	Price     float64   `json:"price,omitempty"`
+	Published time.Time `json:"published"`
+	Tags      []string  `json:"tags"`
+	Author    *author   `json:"author"`
+	Internal  string    `json:"-"`
+	secret    string
+}
+
+var _ = Describe("openapi.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+		SetMountPrefix("")
+	})
+	Describe("describing the registered resources", func() {
+		It("should describe each path and its operations", func() {
+			// Setup
+			store := storetest.Store[book]{}
+			Resource(book{}, "/{title}", store.Get, Put(store.Put), Delete(store.Delete))
+			// Exercise
+			doc := OpenAPI("Books", "1.0")
+			// Verify
+			Expect(doc.OpenAPI).To(Equal("3.0.3"))
+			Expect(doc.Info).To(Equal(OpenAPIInfo{"Books", "1.0"}))
+			item := doc.Paths["/book/{title}"]
+			Expect(item).To(HaveKey("get"))
+			Expect(item).To(HaveKey("put"))
+			Expect(item).To(HaveKey("delete"))
+			Expect(item["get"].Parameters[0]).To(Equal(OpenAPIParameter{Name: "title", In: "path", Required: true, Schema: &Schema{Type: "string"}}))
+			Expect(item["put"].RequestBody.Content["application/json"].Schema.Ref).To(Equal("#/components/schemas/book"))
+		})
+		It("should describe the responses", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			get := OpenAPI("Books", "1.0").Paths["/book"]["get"]
+			// Verify
+			Expect(get.Responses).To(HaveKey("200"))
+			Expect(get.Responses["200"].Content).To(HaveKey("text/html"))
+			Expect(get.Responses["200"].Content["application/json"].Schema.Ref).To(Equal("#/components/schemas/book"))
+			Expect(get.Responses["404"].Content["application/json"].Schema.Ref).To(Equal("#/components/schemas/Error"))
+			Expect(get.Responses).NotTo(HaveKey("428"))
+		})
+		It("should describe query parameters", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler, Paginated(10, 100), Sortable("title"), Filterable("author"))
+			// Exercise
+			get := OpenAPI("Books", "1.0").Paths["/book"]["get"]
+			// Verify
+			names := []string{}
+			for _, parameter := range get.Parameters {
+				names = append(names, parameter.Name)
+			}
+			Expect(names).To(Equal([]string{"fmt", "fields", "expand", "page", "limit", "cursor", "sort", "author"}))
+		})
+		It("should describe the schema of a resource", func() {
+			// Setup
+			SingletonResource(catalogue{}, serverBookHandler)
+			// Exercise
+			schema := OpenAPI("Books", "1.0").Components.Schemas["catalogue"]
+			// Verify
+			Expect(schema.Type).To(Equal("object"))
+			Expect(schema.Properties).To(HaveLen(6))
+			Expect(schema.Properties["title"]).To(Equal(&Schema{Type: "string"}))
+			Expect(schema.Properties["pages"]).To(Equal(&Schema{Type: "integer"}))
+			Expect(schema.Properties["price"]).To(Equal(&Schema{Type: "number"}))
+			Expect(schema.Properties["published"]).To(Equal(&Schema{Type: "string", Format: "date-time"}))
+			Expect(schema.Properties["tags"]).To(Equal(&Schema{Type: "array", Items: &Schema{Type: "string"}}))
+			Expect(schema.Properties["author"].Properties["name"]).To(Equal(&Schema{Type: "string"}))
+		})
+		It("should describe the mount prefix as the server", func() {
+			// Setup
+			SetMountPrefix("/api")
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			doc := OpenAPI("Books", "1.0")
+			// Verify
+			Expect(doc.Servers).To(Equal([]OpenAPIServer{{"/api"}}))
+			Expect(doc.Paths).To(HaveKey("/book"))
+		})
+	})
+	Describe("serving the document", func() {
+		It("should write it as JSON", func() {
+			// Setup
+			SingletonResource(book{}, serverBookHandler)
+			resp := httptest.NewRecorder()
+			// Exercise
+			OpenAPIHandler("Books", "1.0")(resp, request("http://localhost/openapi.json"))
+			// Verify
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+			var doc map[string]interface{}
+			Expect(json.Unmarshal(resp.Body.Bytes(), &doc)).To(Succeed())
+			Expect(doc["openapi"]).To(Equal("3.0.3"))
+			Expect(doc["paths"]).To(HaveKey("/book"))
+		})
+	})
+})
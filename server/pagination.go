@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Page is the part of a collection requested by the client, either by page number or by an opaque cursor
+type Page struct {
+	// Number is the 1-based page number. It is 0 if the client supplied a cursor instead.
+	Number int
+	Limit  int
+	Cursor string
+}
+
+// Offset is the index of the first element of a numbered page
+func (p Page) Offset() int {
+	if p.Number < 1 {
+		return 0
+	}
+	return (p.Number - 1) * p.Limit
+}
+
+// PagedResult is returned by handlers of paginated resources. Only the items are rendered; the rest is sent as Link
+// and X-Total-Count headers.
+type PagedResult struct {
+	Items interface{}
+	Page  Page
+	// Total is the size of the whole collection, or nil if it isn't known
+	Total *int
+	// NextCursor and PrevCursor identify the adjacent pages of a cursor-based collection
+	NextCursor string
+	PrevCursor string
+}
+
+type paginationConfig struct {
+	defaultLimit int
+	maxLimit     int
+}
+
+// Paginated parses the page, limit and cursor parameters for the resource, making them available to its handler via
+// RequestPage. Requests for more than maxLimit items are rejected. Slices returned by the handler are paginated
+// automatically, so only handlers which can fetch a page more cheaply need look at the request.
+func Paginated(defaultLimit int, maxLimit int) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.pagination = &paginationConfig{defaultLimit, maxLimit}
+	}
+}
+
+type pageKey struct{}
+
+// RequestPage returns the page requested by the client, if the resource is paginated
+func RequestPage(ctx context.Context) (Page, bool) {
+	page, ok := ctx.Value(pageKey{}).(Page)
+	return page, ok
+}
+
+func badPageParameter(name string, value string) *RequestError {
+	return &RequestError{Error: fmt.Errorf("Invalid %s [%s]", name, value), Message: fmt.Sprintf("'%s' is not a valid %s", value, name), Code: http.StatusBadRequest}
+}
+
+func parsePage(r *http.Request, config *paginationConfig) (Page, *RequestError) {
+	query := r.URL.Query()
+	page := Page{Number: 1, Limit: config.defaultLimit, Cursor: query.Get("cursor")}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > config.maxLimit {
+			return page, badPageParameter("limit", value)
+		}
+		page.Limit = limit
+	}
+	if page.Cursor != "" {
+		page.Number = 0
+	} else if value := query.Get("page"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			return page, badPageParameter("page", value)
+		}
+		page.Number = number
+	}
+	return page, nil
+}
+
+func withPage(r *http.Request, entry mutexEntry) (*http.Request, *RequestError) {
+	if entry.pagination == nil {
+		return r, nil
+	}
+	page, err := parsePage(r, entry.pagination)
+	if err != nil {
+		return r, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), pageKey{}, page)), nil
+}
+
+// Paginate returns the requested page of a slice, for handlers which hold their whole collection in memory
+func Paginate(items interface{}, page Page) PagedResult {
+	v := reflect.ValueOf(items)
+	total := v.Len()
+	start := page.Offset()
+	if start > total {
+		start = total
+	}
+	end := start + page.Limit
+	if end > total || page.Limit < 1 {
+		end = total
+	}
+	return PagedResult{Items: v.Slice(start, end).Interface(), Page: page, Total: &total}
+}
+
+func pageURL(r *http.Request, set map[string]string) string {
+	query := r.URL.Query()
+	for _, name := range []string{"page", "cursor"} {
+		query.Del(name)
+	}
+	for name, value := range set {
+		query.Set(name, value)
+	}
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func pageLinks(r *http.Request, result PagedResult) []string {
+	page := result.Page
+	links := make([]string, 0, 4)
+	link := func(rel string, set map[string]string) {
+		// Handlers of unpaginated resources may still return a PagedResult, without a limit
+		if page.Limit > 0 {
+			set["limit"] = strconv.Itoa(page.Limit)
+		}
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", pageURL(r, set), rel))
+	}
+
+	if page.Number == 0 || result.NextCursor != "" || result.PrevCursor != "" {
+		if result.NextCursor != "" {
+			link("next", map[string]string{"cursor": result.NextCursor})
+		}
+		if result.PrevCursor != "" {
+			link("prev", map[string]string{"cursor": result.PrevCursor})
+		}
+		link("first", map[string]string{})
+		return links
+	}
+
+	last := 0
+	if result.Total != nil && page.Limit > 0 {
+		last = (*result.Total + page.Limit - 1) / page.Limit
+	}
+	// Without a total, a full page suggests there may be another
+	if (result.Total != nil && page.Number < last) || (result.Total == nil && reflect.ValueOf(result.Items).Len() == page.Limit) {
+		link("next", map[string]string{"page": strconv.Itoa(page.Number + 1)})
+	}
+	// A page beyond the last is empty, so the previous page with items is the last
+	prev := page.Number - 1
+	if result.Total != nil && prev > last {
+		prev = last
+	}
+	if prev >= 1 {
+		link("prev", map[string]string{"page": strconv.Itoa(prev)})
+	}
+	link("first", map[string]string{"page": "1"})
+	if last > 0 {
+		link("last", map[string]string{"page": strconv.Itoa(last)})
+	}
+	return links
+}
+
+// unwrapPage sets the pagination headers for a paged result, and returns the items to render
+func unwrapPage(i interface{}, wr interface{}, r *http.Request) interface{} {
+	result, ok := i.(PagedResult)
+	if !ok {
+		return i
+	}
+	if w, isResponse := wr.(http.ResponseWriter); isResponse {
+		w.Header().Set("Link", strings.Join(pageLinks(r, result), ", "))
+		if result.Total != nil {
+			w.Header().Set("X-Total-Count", strconv.Itoa(*result.Total))
+		}
+	}
+	return result.Items
+}
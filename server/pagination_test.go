@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"fmt"
+	"net/http"
+)
+
+var shelf = []book{
+	book{"Neuromancer", "Gibson, William"},
+	book{"Count Zero", "Gibson, William"},
+	book{"Mona Lisa Overdrive", "Gibson, William"},
+	book{"Pandora's Star", "Peter F. Hamilton"},
+	book{"Judas Unchained", "Peter F. Hamilton"}}
+
+func shelfHandler(params PathParameters) (interface{}, *RequestError) {
+	page, _ := RequestPage(params.Context())
+	return Paginate(shelf, page), nil
+}
+
+var _ = Describe("pagination.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("Paginate", func() {
+		It("should return the requested page and total", func() {
+			// Exercise
+			result := Paginate(shelf, Page{Number: 2, Limit: 2})
+			// Verify
+			Expect(result.Items).To(Equal(shelf[2:4]))
+			Expect(result.Total).To(HaveValue(Equal(5)))
+		})
+		It("should return a short last page", func() {
+			// Exercise
+			result := Paginate(shelf, Page{Number: 3, Limit: 2})
+			// Verify
+			Expect(result.Items).To(Equal(shelf[4:]))
+		})
+		It("should return no items beyond the end", func() {
+			// Exercise
+			result := Paginate(shelf, Page{Number: 4, Limit: 2})
+			// Verify
+			Expect(result.Items).To(BeEmpty())
+		})
+	})
+	Describe("requesting a paginated resource", func() {
+		It("should pass the default page to the handler", func() {
+			// Setup
+			var requested Page
+			SingletonResource(book{}, func(params PathParameters) (interface{}, *RequestError) {
+				requested, _ = RequestPage(params.Context())
+				return shelf, nil
+			}, Paginated(2, 10))
+			// Exercise
+			get("http://localhost/book")
+			// Verify
+			Expect(requested).To(Equal(Page{Number: 1, Limit: 2}))
+		})
+		It("should pass a cursor to the handler", func() {
+			// Setup
+			var requested Page
+			SingletonResource(book{}, func(params PathParameters) (interface{}, *RequestError) {
+				requested, _ = RequestPage(params.Context())
+				return shelf, nil
+			}, Paginated(2, 10))
+			// Exercise
+			get("http://localhost/book?cursor=abc&limit=3")
+			// Verify
+			Expect(requested).To(Equal(Page{Limit: 3, Cursor: "abc"}))
+		})
+		It("should not pass a page to unpaginated handlers", func() {
+			// Setup
+			found := true
+			SingletonResource(book{}, func(params PathParameters) (interface{}, *RequestError) {
+				_, found = RequestPage(params.Context())
+				return shelf, nil
+			})
+			// Exercise
+			get("http://localhost/book?page=2")
+			// Verify
+			Expect(found).To(BeFalse())
+		})
+		It("should render only the items", func() {
+			// Setup
+			SingletonResource(book{}, shelfHandler, Paginated(2, 10))
+			// Exercise
+			resp := get("http://localhost/book?page=3&fmt=json")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`[{"title":"Judas Unchained","author":"Peter F. Hamilton"}]`))
+			Expect(resp.Header().Get("X-Total-Count")).To(Equal("5"))
+		})
+		It("should link to the adjacent, first and last pages", func() {
+			// Setup
+			SingletonResource(book{}, shelfHandler, Paginated(2, 10))
+			// Exercise
+			resp := get("http://localhost/book?page=2&fmt=json")
+			// Verify
+			Expect(resp.Header().Get("Link")).To(Equal(
+				`</book?fmt=json&limit=2&page=3>; rel="next", ` +
+					`</book?fmt=json&limit=2&page=1>; rel="prev", ` +
+					`</book?fmt=json&limit=2&page=1>; rel="first", ` +
+					`</book?fmt=json&limit=2&page=3>; rel="last"`))
+		})
+		It("should not link beyond the last page", func() {
+			// Setup
+			SingletonResource(book{}, shelfHandler, Paginated(2, 10))
+			// Exercise
+			resp := get("http://localhost/book?page=3&fmt=json")
+			// Verify
+			Expect(resp.Header().Get("Link")).NotTo(ContainSubstring(`rel="next"`))
+		})
+		It("should not send links or totals with errors", func() {
+			// Setup
+			SingletonResource(book{}, shelfHandler, Paginated(2, 10))
+			// Exercise
+			resp := get("http://localhost/book?page=2&fmt=json&fields=nope")
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Header().Values("Link")).To(BeEmpty())
+			Expect(resp.Header().Values("X-Total-Count")).To(BeEmpty())
+		})
+		It("should link to the next page of a full page without a total", func() {
+			// Setup
+			SingletonResource(book{}, func(params PathParameters) (interface{}, *RequestError) {
+				return PagedResult{Items: shelf[:2]}, nil
+			}, Paginated(2, 10))
+			// Exercise
+			resp := get("http://localhost/book?fmt=json")
+			// Verify
+			Expect(resp.Header().Get("Link")).To(Equal(`</book?fmt=json&limit=2&page=2>; rel="next", </book?fmt=json&limit=2&page=1>; rel="first"`))
+			Expect(resp.Header().Values("X-Total-Count")).To(BeEmpty())
+		})
+		It("should link to cursors", func() {
+			// Setup
+			SingletonResource(book{}, func(params PathParameters) (interface{}, *RequestError) {
+				return PagedResult{Items: shelf[2:4], NextCursor: "d", PrevCursor: "a"}, nil
+			}, Paginated(2, 10))
+			// Exercise
+			resp := get("http://localhost/book?cursor=b&fmt=json")
+			// Verify
+			Expect(resp.Header().Get("Link")).To(Equal(
+				`</book?cursor=d&fmt=json&limit=2>; rel="next", </book?cursor=a&fmt=json&limit=2>; rel="prev", </book?fmt=json&limit=2>; rel="first"`))
+			Expect(resp.Header().Values("X-Total-Count")).To(BeEmpty())
+		})
+		It("should link back to the last page from beyond it", func() {
+			// Setup
+			SingletonResource(book{}, shelfHandler, Paginated(2, 10))
+			// Exercise
+			resp := get("http://localhost/book?page=99&fmt=json")
+			// Verify
+			Expect(resp.Header().Get("Link")).To(Equal(
+				`</book?fmt=json&limit=2&page=3>; rel="prev", ` +
+					`</book?fmt=json&limit=2&page=1>; rel="first", ` +
+					`</book?fmt=json&limit=2&page=3>; rel="last"`))
+		})
+		It("should not send a limit for unpaginated resources", func() {
+			// Setup
+			SingletonResource(book{}, func(params PathParameters) (interface{}, *RequestError) {
+				return PagedResult{Items: shelf[:2], NextCursor: "c"}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/book?fmt=json")
+			// Verify
+			Expect(resp.Header().Get("Link")).To(Equal(`</book?cursor=c&fmt=json>; rel="next", </book?fmt=json>; rel="first"`))
+		})
+		for _, query := range []string{"limit=0", "limit=11", "limit=x", "page=0", "page=x"} {
+			query := query
+			It(fmt.Sprintf("should reject %s", query), func() {
+				// Setup
+				SingletonResource(book{}, shelfHandler, Paginated(2, 10))
+				// Exercise
+				resp := get("http://localhost/book?" + query)
+				// Verify
+				Expect(resp.Code).To(Equal(400))
+			})
+		}
+	})
+})
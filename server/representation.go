@@ -2,14 +2,17 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	hTemplate "html/template"
 	"io"
-	"log"
+	"mime"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	tTemplate "text/template"
 )
 
@@ -31,12 +34,12 @@ func (at anyTemplate) execute(i interface{}, w io.Writer) error {
 }
 
 func parseHtmlTemplate(filename string) (*anyTemplate, error) {
-	var t, err = hTemplate.ParseFiles(filename)
+	var t, err = hTemplate.New(filepath.Base(filename)).Funcs(templateFuncs).ParseFiles(filename)
 	return &anyTemplate{t}, err
 }
 
 func parseTextTemplate(filename string) (*anyTemplate, error) {
-	var t, err = tTemplate.ParseFiles(filename)
+	var t, err = tTemplate.New(filepath.Base(filename)).Funcs(templateFuncs).ParseFiles(filename)
 	return &anyTemplate{t}, err
 }
 
@@ -51,66 +54,243 @@ func parseTemplate(i interface{}, format string, filename string) (*anyTemplate,
 	}
 }
 
-func loadTemplate(i interface{}, format string) (*anyTemplate, *RequestError) {
+func parseBuiltinTemplate(format string, name string, src string) (*anyTemplate, *RequestError) {
+	var t template
+	var err error
+	if format == "html" {
+		t, err = hTemplate.New(name).Funcs(templateFuncs).Parse(src)
+	} else {
+		t, err = tTemplate.New(name).Funcs(templateFuncs).Parse(src)
+	}
+	if err != nil {
+		return nil, internalRequestError(err)
+	}
+	return &anyTemplate{t}, nil
+}
+
+func loadTemplate(ctx context.Context, i interface{}, format string) (*anyTemplate, *RequestError) {
+	_, span := startSpan(ctx, "gowest.template")
+	span.SetAttribute("gowest.format", format)
+	t, err := loadTemplateFile(ctx, i, format)
+	endSpan(span, err)
+	return t, err
+}
+
+func loadTemplateFile(ctx context.Context, i interface{}, format string) (*anyTemplate, *RequestError) {
 	// FIXME Security risk - using client data input
 	// TODO Add in support for a list of supported formats, the use of which should be recommended
 	// TODO If taking the format from the client (i.e. there is no list of valid formats), lower case it
 	filename := fmtType(i) + "." + format;
 	// TODO Extract to a "exists" method
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		log.Printf("Template [%s] does not exist: %v", filename, err)
+		if src, ok := builtinTemplates[filename]; ok {
+			return parseBuiltinTemplate(format, filename, src)
+		}
+		logf(ctx, "Template [%s] does not exist: %v", filename, err)
 		return nil, &RequestError{Error: err, Message: fmt.Sprintf("'%s' is not a supported format", format), Code: http.StatusNotAcceptable}
 	}
 	t, err := parseTemplate(i, format, filename);
 	if err != nil {
-		log.Printf("Unable to parse template: %v", err)
+		logf(ctx, "Unable to parse template: %v", err)
 		return nil, internalRequestError(err)
 	}
 	return t, nil
 }
 
-func getJsonBytes(i interface{}) ([]byte, *RequestError) {
+func writeJsonValue(ctx context.Context, i interface{}, w io.Writer) error {
 	bytes, err := json.Marshal(i)
 	if err != nil {
-		log.Printf("Unable to marshall instance of [%v] ([%v]): %v", fmtType(i), i, err)
-		return nil, internalRequestError(err)
+		logf(ctx, "Unable to marshall instance of [%v] ([%v]): %v", fmtType(i), i, err)
+		return err
 	}
-	return bytes, nil
+	_, err = w.Write(bytes)
+	return err
 }
 
-func getTemplateBytes(i interface{}, format string) ([]byte, *RequestError) {
-	template, err := loadTemplate(i, format)
+func writeJson(ctx context.Context, i interface{}, w io.Writer) *RequestError {
+	if !isSequence(i) {
+		if err := writeJsonValue(ctx, i, w); err != nil {
+			return internalRequestError(err)
+		}
+		return nil
+	}
+
+	// Sequences are written element by element, so they needn't be held in memory
+	first := true
+	err := forEach(ctx, i, func(element interface{}) error {
+		separator := ","
+		if first {
+			separator, first = "[", false
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		return writeJsonValue(ctx, element, w)
+	})
+	if err == nil && first {
+		_, err = io.WriteString(w, "[")
+	}
+	if err == nil {
+		_, err = io.WriteString(w, "]")
+	}
 	if err != nil {
-		return nil, err;
+		logf(ctx, "Unable to write sequence: %v", err)
+		return internalRequestError(err)
 	}
-	// TODO Allocate the buffer to be the same size of the template
-	// The execution process writes directly to the buffer, so it may write bytes before finding an error
-	buff := new(bytes.Buffer)
-	if err := template.execute(i, buff); err != nil {
-		log.Printf("Unable to process template [%v]", err)
-		return nil, internalRequestError(err)
+	return nil
+}
+
+func writeTemplate(ctx context.Context, i interface{}, format string, w io.Writer) *RequestError {
+	template, err := loadTemplate(ctx, i, format)
+	if err != nil {
+		return err;
 	}
-	return buff.Bytes(), nil
+	// The execution process writes directly to the writer, so it may write bytes before finding an error
+	if err := template.execute(i, w); err != nil {
+		logf(ctx, "Unable to process template [%v]", err)
+		return internalRequestError(err)
+	}
+	return nil
 }
 
-func getBytes(i interface{}, r *http.Request) ([]byte, *RequestError) {
+func requestFormat(r *http.Request) string {
 	// TODO Default to first format in list if none is specified
 	// If the fmt parameter appears twice, we take the first one
-	if format := r.URL.Query().Get("fmt"); format != "json" {
-		return getTemplateBytes(i, format)
-	} else {
-		return getJsonBytes(i)
+	if format := r.URL.Query().Get("fmt"); format != "" {
+		return format
+	}
+	if format := negotiateFormat(r); format != "" {
+		return format
+	}
+	// A write's body is JSON, so its response is too unless the client asks for something else
+	if r.Method == "PUT" || r.Method == "DELETE" {
+		return "json"
 	}
+	return ""
 }
 
-func MarshallResponse(i interface{}, wr io.Writer, r *http.Request) *RequestError {
+// isSupportedFormat reports whether a registered resource can be rendered in a format, by an encoder or a template for
+// its type
+func isSupportedFormat(entry mutexEntry, format string) bool {
+	if entry.handler == nil || format == "" {
+		return false
+	}
+	if _, ok := getEncoder(format); ok {
+		return true
+	}
+	if entry.resourceType == nil {
+		return false
+	}
+	filename := entry.resourceType.String() + "." + format
+	if _, ok := builtinTemplates[filename]; ok {
+		return true
+	}
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func contentType(format string, b []byte) string {
+	if entry, ok := getEncoder(format); ok {
+		return entry.mediaType
+	}
+	if t := mime.TypeByExtension("." + format); t != "" {
+		return t
+	}
+	return http.DetectContentType(b)
+}
+
+// setRepresentationHeaders sets the headers describing the representation, given at least its first bytes
+func setRepresentationHeaders(w http.ResponseWriter, r *http.Request, b []byte) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", contentType(requestFormat(r), b))
+	}
+	if r.URL.Query().Get("fmt") == "" {
+		addVary(w, "Accept")
+	}
+	varyEncoding(w)
+}
+
+func render(i interface{}, w io.Writer, r *http.Request) *RequestError {
+	format := requestFormat(r)
+	if entry, ok := getEncoder(format); ok {
+		return entry.encoder(i, w, r)
+	}
+	return writeTemplate(r.Context(), i, format, w)
+}
+
+func getBytes(i interface{}, r *http.Request) ([]byte, *RequestError) {
+	// TODO Allocate the buffer to be the same size of the template
+	buff := new(bytes.Buffer)
+	if err := render(i, buff, r); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func MarshallResponse(i interface{}, wr io.Writer, r *http.Request) (err *RequestError) {
+	ctx, span := startSpan(r.Context(), "gowest.render")
+	span.SetAttribute("gowest.format", requestFormat(r))
+	defer func() { endSpan(span, err) }()
+	r = r.WithContext(ctx)
+
+	// Headers can only be set when writing to a client rather than, say, a buffer
+	rw, isResponse := wr.(http.ResponseWriter)
+	i = unwrapPage(i, wr, r)
+	v := resourceValidators(i, requestFormat(r))
+	i, projected, err := projectFields(i, r)
+	if err != nil {
+		return err
+	}
+	i, expanded, err := expandResources(i, r)
+	if err != nil {
+		return err
+	}
+	if projected || expanded {
+		// The resource's ETag is for the whole representation
+		v.etag = ""
+	}
+	if isResponse && v.etag != "" {
+		// The resource supplied its own ETag, so we needn't render it at all if we know which variant it would be
+		if etag, known := variantETag(rw, r, v.etag); known && writeValidators(rw, r, validators{etag: etag, lastModified: v.lastModified}) {
+			return nil
+		}
+	}
+
+	if threshold := getStreamingThreshold(); isResponse && threshold > 0 {
+		return streamResponse(i, rw, r, v, threshold)
+	} else if isResponse && isSequence(i) && isRecordFormat(requestFormat(r)) {
+		// Clients of record formats expect each record as soon as it's produced
+		return streamResponse(i, rw, r, v, 0)
+	}
 	bytes, err := getBytes(i, r)
 	if err != nil {
 		return err
 	}
+	return writeBytes(bytes, wr, r, v)
+}
+
+// writeBytes writes a complete representation, along with the headers that can only be determined from all of it
+func writeBytes(bytes []byte, wr io.Writer, r *http.Request, v validators) *RequestError {
+	if v.etag == "" {
+		v.etag = bytesETag(bytes)
+	}
+	if rw, isResponse := wr.(http.ResponseWriter); isResponse {
+		setRepresentationHeaders(rw, r, bytes)
+		// A compressed variant has its own tag, which a 304 must repeat, so the encoding is chosen first
+		encoding := responseEncoding(rw, r, len(bytes))
+		if encoding != "" {
+			v.etag = encodedETag(v.etag, encoding)
+		}
+		if writeValidators(rw, r, v) {
+			return nil
+		}
+		bytes, v.etag = compressResponse(rw, r, bytes, encoding, v.etag)
+		rw.Header().Set("ETag", v.etag)
+		rw.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	}
 	if _, err := wr.Write(bytes); err != nil {
 		// At this point, it's likely we won't be able to write this internal service error anyway
-		log.Printf("Unable to write response [%v]: %v", string(bytes), err)
+		logf(r.Context(), "Unable to write response [%v]: %v", string(bytes), err)
 		return internalRequestError(err)
 	}
 	return nil
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/cleggatt/gowest/api"
+)
+
+// RequestIDHeader is read to find the caller's request ID, and echoed in every response
+const RequestIDHeader = api.RequestIDHeader
+
+// Incoming IDs end up in logs and response headers, so we only accept a conservative set of characters
+var requestIDRegex = regexp.MustCompile("^[A-Za-z0-9._:-]{1,128}$")
+
+// RequestID returns the ID of the request being handled, or "" if there is none
+func RequestID(ctx context.Context) string {
+	return api.RequestID(ctx)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms, but an ID isn't worth failing a request over
+		log.Printf("Unable to generate request ID: %v", err)
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(RequestIDHeader)
+	if !requestIDRegex.MatchString(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(api.WithRequestID(r.Context(), id))
+}
+
+// logf logs with the ID of the current request, if any
+func logf(ctx context.Context, format string, v ...interface{}) {
+	if id := RequestID(ctx); id != "" {
+		format = "[" + id + "] " + format
+	}
+	log.Printf(format, v...)
+}
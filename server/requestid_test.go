@@ -0,0 +1,69 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http/httptest"
+)
+
+func createRequestIDAccumulator(acc *string) GetHandler {
+	return func(params PathParameters) (interface{}, *RequestError) {
+		*acc = RequestID(params.Context())
+		return book{"Neuromancer", "Gibson, William"}, nil
+	}
+}
+
+var _ = Describe("requestid.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("handling a request", func() {
+		It("should use the caller's request ID", func() {
+			// Setup
+			var id string
+			SingletonResource(book{}, createRequestIDAccumulator(&id))
+			req := request("http://localhost:8080/book?fmt=json")
+			req.Header = map[string][]string{"X-Request-Id": {"abc-123"}}
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, req)
+			// Verify
+			Expect(id).To(Equal("abc-123"))
+			Expect(resp.Header().Get(RequestIDHeader)).To(Equal("abc-123"))
+		})
+		It("should generate a request ID if there is none", func() {
+			// Setup
+			var id string
+			SingletonResource(book{}, createRequestIDAccumulator(&id))
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(id).To(MatchRegexp("^[0-9a-f]{32}$"))
+			Expect(resp.Header().Get(RequestIDHeader)).To(Equal(id))
+		})
+		It("should replace an invalid request ID", func() {
+			// Setup
+			var id string
+			SingletonResource(book{}, createRequestIDAccumulator(&id))
+			req := request("http://localhost:8080/book?fmt=json")
+			req.Header = map[string][]string{"X-Request-Id": {"abc\n123"}}
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, req)
+			// Verify
+			Expect(id).To(MatchRegexp("^[0-9a-f]{32}$"))
+			Expect(resp.Header().Get(RequestIDHeader)).To(Equal(id))
+		})
+		It("should generate a different ID for each request", func() {
+			// Exercise
+			first, second := httptest.NewRecorder(), httptest.NewRecorder()
+			MainHandler(first, request("http://localhost:8080/Missing?fmt=json"))
+			MainHandler(second, request("http://localhost:8080/Missing?fmt=json"))
+			// Verify
+			Expect(first.Header().Get(RequestIDHeader)).ToNot(Equal(second.Header().Get(RequestIDHeader)))
+		})
+	})
+})
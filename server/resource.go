@@ -1,18 +1,26 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/cleggatt/gowest/api"
 )
 
 type PathParameters interface {
 	Get(param string) (string, *RequestError)
 	AsMap() map[string]string
+	// Context returns the context of the request being handled. It carries the request ID and is cancelled if the
+	// client goes away.
+	Context() context.Context
 	// TODO Add GetAsInt, etc. Will need to distinguish between missing and invalid
 }
 
@@ -27,6 +35,15 @@ func (m parameterMap) AsMap() map[string]string {
 	return m
 }
 
+type requestParameters struct {
+	parameterMap
+	ctx context.Context
+}
+
+func (p requestParameters) Context() context.Context {
+	return p.ctx
+}
+
 // TODO Should we have a type with no PathParameters?
 type GetHandler func(params PathParameters) (interface{}, *RequestError)
 
@@ -38,103 +55,280 @@ type handlerMutex struct {
 
 type mutexEntry struct {
 	typeName string
+	resourceType reflect.Type
 	parameters []string
 	handler GetHandler
+	putHandler PutHandler
+	deleteHandler DeleteHandler
+	requirePreconditions bool
+	timeout time.Duration
+	cachePolicy *CachePolicy
+	serverCacheTTL time.Duration
+	coalesce bool
+	pagination *paginationConfig
+	sortable []string
+	filterable []string
+	collection bool
 }
 
+// ResourceOption configures optional behaviour of a registered resource
+type ResourceOption func(entry *mutexEntry)
+
 func newHandlerMutex() *handlerMutex { return &handlerMutex{handlers: make(map[string]mutexEntry)} }
 
-func (mutex *handlerMutex) registerHandler(typeName string, parameters []string, handler GetHandler) {
+func (mutex *handlerMutex) registerHandler(entry mutexEntry) error {
 	mutex.mutex.Lock()
 	defer mutex.mutex.Unlock()
 
-	mutex.handlers[typeName] = mutexEntry{typeName: typeName, parameters: parameters, handler: handler }
+	if err := mutex.checkName(entry); err != nil {
+		return err
+	}
+	mutex.handlers[nameKey(entry.typeName)] = entry
+	return nil
 }
 
-func (mutex *handlerMutex) getHandler(typeName string) (handler GetHandler, parameters []string) {
+func (mutex *handlerMutex) getHandler(typeName string) mutexEntry {
 	mutex.mutex.RLock()
 	defer mutex.mutex.RUnlock()
 
-	// TODO Handle missing map entry
-	entry := mutex.handlers[typeName]
-	return entry.handler, entry.parameters
+	// A missing entry has a nil handler
+	return mutex.handlers[nameKey(typeName)]
 }
 
 func ClearHandlers() {
 	// FIXME This is not threadsafe, but is just used for tests ATM
 	defaultHandlerMutex = newHandlerMutex()
+	defaultResponseCache = newResponseCache()
 }
 
 var defaultHandlerMutex = newHandlerMutex()
 
 func getInterfaceTypeName(i interface{}) (t reflect.Type, name string) {
 	t = reflect.TypeOf(i)
-	if t.Kind() == reflect.Ptr {
-		pointerToValue := reflect.ValueOf(i)
-		valueAtPointer := reflect.Indirect(pointerToValue)
-		t = valueAtPointer.Type()
+	// Generic registrations pass a new(T), so a T which is itself a pointer gives more than one level
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
-	name = t.Name()
+	name = api.ResourceName(i)
 	return
 }
 
-// TODO Support more characters
-var patternRegex = regexp.MustCompile("/\\{([a-z_]+)\\}")
-
-func extractParameters(pattern string) []string {
-	// TODO Validate pattern
-	parameterElements := patternRegex.FindAllStringSubmatch(pattern, -1)
-
-	parameters := make([]string, len(parameterElements))
-	for idx, element := range parameterElements {
-		parameters[idx] = element[1]
+// entryFor finds the resource registered for an instance of a type, which may have been registered with another name
+func (mutex *handlerMutex) entryFor(i interface{}) mutexEntry {
+	t, name := getInterfaceTypeName(i)
+	if entry := mutex.getHandler(name); entry.resourceType == t {
+		return entry
 	}
+	for _, entry := range mutex.entries() {
+		if entry.resourceType == t {
+			return entry
+		}
+	}
+	return mutexEntry{}
+}
 
-	return parameters
+// ResourceName is the name a resource is registered under, given an instance of its type. It's the first element of
+// the resource's URLs.
+func ResourceName(i interface{}) string {
+	_, name := getInterfaceTypeName(i)
+	return name
 }
 
-var argumentRegex = regexp.MustCompile("/([A-Za-z_]+)")
+// PatternParameters lists the parameters of a pattern passed to Resource, in the order they appear in URLs
+func PatternParameters(pattern string) []string {
+	return api.PatternParameters(pattern)
+}
+
+var parameterRegex = regexp.MustCompile("^[a-z_]+$")
+
+// validatePattern checks a pattern passed to Resource is a sequence of parameters e.g. /{surname}/{firstname}, and
+// returns them
+func validatePattern(pattern string) ([]string, error) {
+	if strings.Count(pattern, "{") != strings.Count(pattern, "}") {
+		return nil, fmt.Errorf("Pattern [%s] has unbalanced braces", pattern)
+	}
+	if pattern != "" && !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("Pattern [%s] must start with /", pattern)
+	}
+	parameters := make([]string, 0)
+	for _, segment := range strings.Split(pattern, "/")[1:] {
+		parameter := strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}")
+		if len(parameter) != len(segment)-2 {
+			return nil, fmt.Errorf("Pattern [%s] has [%s], which isn't a parameter e.g. {title}", pattern, segment)
+		}
+		if !parameterRegex.MatchString(parameter) {
+			return nil, fmt.Errorf("Parameter [%s] in pattern [%s] may only contain a-z and _", parameter, pattern)
+		}
+		if contains(parameters, parameter) {
+			return nil, fmt.Errorf("Parameter [%s] appears more than once in pattern [%s]", parameter, pattern)
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters, nil
+}
 
-func extractPathParameters(argumentPath string, parameters []string) PathParameters {
+// extractPathParameters takes the value of each parameter from a segment of the escaped path following the resource's
+// name. Each segment is unescaped separately, so values containing any character, including "/", round-trip through
+// URLFor.
+func extractPathParameters(argumentPath string, parameters []string) (parameterMap, error) {
 	// TODO Validate elements against expected parameters OR pass in remaining values in list. Perhaps use "*" to allow this
-	argumentElements := argumentRegex.FindAllStringSubmatch(argumentPath, -1)
+	segments := strings.Split(strings.TrimPrefix(argumentPath, "/"), "/")
 
 	pathParams := parameterMap(make(map[string]string, len(parameters)))
 	for idx, element := range parameters {
-		pathParams[element] = argumentElements[idx][1]
+		if idx >= len(segments) || segments[idx] == "" {
+			return nil, fmt.Errorf("Missing parameter [%s] in [%s]", element, argumentPath)
+		}
+		value, err := url.PathUnescape(segments[idx])
+		if err != nil {
+			return nil, err
+		}
+		pathParams[element] = value
 	}
 
-	return pathParams
+	return pathParams, nil
 }
 
-func SingletonResource(i interface{}, handler GetHandler) {
-	t, name := getInterfaceTypeName(i)
-	log.Printf("Registering GET handler for [%s] as [%s]\n", t.String(), name)
-	defaultHandlerMutex.registerHandler(name, make([]string, 0), handler)
+func newMutexEntry(t reflect.Type, name string, parameters []string, handler GetHandler, options []ResourceOption) mutexEntry {
+	entry := mutexEntry{typeName: name, resourceType: t, parameters: parameters, handler: handler}
+	for _, option := range options {
+		option(&entry)
+	}
+	return entry
 }
 
-func Resource(i interface{}, parameterPattern string, handler GetHandler) {
+func registerResource(i interface{}, parameterPattern string, handler GetHandler, options []ResourceOption) error {
+	if i == nil {
+		return fmt.Errorf("A resource must be registered with an instance of its type")
+	}
 	t, name := getInterfaceTypeName(i)
-	parameters := extractParameters(parameterPattern)
-	log.Printf("Registering GET handler for [%s] as [%s] with [%s]\n", t.String(), name, parameterPattern)
-	defaultHandlerMutex.registerHandler(name, parameters, handler)
+	parameters, err := validatePattern(parameterPattern)
+	if err != nil {
+		return err
+	}
+	entry := newMutexEntry(t, name, parameters, handler, options)
+	if handler == nil {
+		return fmt.Errorf("No GET handler for [%s]", entry.typeName)
+	}
+	log.Printf("Registering GET handler for [%s] as [%s] with [%s]\n", t.String(), entry.typeName, parameterPattern)
+	return defaultHandlerMutex.registerHandler(entry)
 }
 
-func GetResource(r *http.Request) (interface{}, *RequestError) {
+// SingletonResource registers a resource without parameters. It returns an error, and registers nothing, if the
+// resource can't be registered.
+func SingletonResource(i interface{}, handler GetHandler, options ...ResourceOption) error {
+	return registerResource(i, "", handler, options)
+}
+
+// Resource registers a resource with the parameters in parameterPattern e.g. "/{surname}/{firstname}". It returns an
+// error, and registers nothing, if the pattern is invalid, the handler is nil or another type has the same name.
+func Resource(i interface{}, parameterPattern string, handler GetHandler, options ...ResourceOption) error {
+	return registerResource(i, parameterPattern, handler, options)
+}
+
+// MustSingletonResource is like SingletonResource, but panics if the resource can't be registered
+func MustSingletonResource(i interface{}, handler GetHandler, options ...ResourceOption) {
+	if err := SingletonResource(i, handler, options...); err != nil {
+		panic(err)
+	}
+}
+
+// MustResource is like Resource, but panics if the resource can't be registered. It suits registration at startup,
+// where a resource which can't be registered is a programming error.
+func MustResource(i interface{}, parameterPattern string, handler GetHandler, options ...ResourceOption) {
+	if err := Resource(i, parameterPattern, handler, options...); err != nil {
+		panic(err)
+	}
+}
+
+// shapeCollection filters, sorts and paginates a collection as requested by the client. Handlers which return a
+// PagedResult are responsible for choosing its items.
+func shapeCollection(resource interface{}, r *http.Request, entry mutexEntry) (interface{}, *RequestError) {
+	page, paginated := RequestPage(r.Context())
+	if result, ok := resource.(PagedResult); ok {
+		if result.Page == (Page{}) {
+			result.Page = page
+		}
+		return result, nil
+	}
+	resource, err := filterAndSort(resource, r, entry)
+	if err != nil {
+		return nil, err
+	}
+	if v := reflect.ValueOf(resource); paginated && v.Kind() == reflect.Slice {
+		return Paginate(resource, page), nil
+	}
+	return resource, nil
+}
+
+func requestTypeName(r *http.Request) string {
 	// TODO Handle invalid URLs when determining typeName and suffix. Note, we should always have a leading "/"
-	typeName := strings.Trim(strings.SplitAfterN(r.URL.Path, "/", 3)[1], "/")
-	argumentPath := strings.TrimPrefix(r.URL.Path, "/" + typeName)
-	log.Printf("GET request for [%v] [%v]\n", typeName, argumentPath)
+	path, ok := mountedPath(r.URL.EscapedPath())
+	if !ok {
+		return ""
+	}
+	return strings.Trim(strings.SplitAfterN(path, "/", 3)[1], "/")
+}
 
-	handler, parameters := defaultHandlerMutex.getHandler(typeName)
-	if handler == nil {
-		log.Printf("No handler registered for %s", typeName)
-		return nil, &RequestError{Error: fmt.Errorf("No handler registered for %s", typeName), Message: "Invalid resource type", Code: http.StatusNotFound}
+// requestResourceName is the name of the requested resource as it was registered, whatever its case in the URL
+func requestResourceName(r *http.Request) string {
+	typeName := requestTypeName(r)
+	if entry := defaultHandlerMutex.getHandler(typeName); entry.handler != nil {
+		return entry.typeName
+	}
+	return typeName
+}
+
+func requestMethod(r *http.Request) string {
+	if r.Method == "" {
+		return "GET"
 	}
-	log.Printf("Found GET handler for [%v] with [%v]\n", typeName, parameters)
+	return r.Method
+}
+
+func resolveResource(r *http.Request) (entry mutexEntry, params parameterMap, err *RequestError) {
+	_, resolveSpan := startSpan(r.Context(), "gowest.resolve")
+	defer func() { endSpan(resolveSpan, err) }()
 
-	pathParameters := extractPathParameters(argumentPath, parameters)
+	typeName := requestTypeName(r)
+	path, _ := mountedPath(r.URL.EscapedPath())
+	argumentPath := strings.TrimPrefix(path, "/" + typeName)
+	logf(r.Context(), "%s request for [%v] [%v]\n", requestMethod(r), typeName, argumentPath)
+	resolveSpan.SetAttribute("gowest.type", typeName)
 
-	resource, err := handler(pathParameters)
-	return resource, err
+	entry = defaultHandlerMutex.getHandler(typeName)
+	if entry.handler == nil {
+		entry = builtinEntry(r, typeName)
+	}
+	if entry.handler == nil {
+		logf(r.Context(), "No handler registered for %s", typeName)
+		return entry, nil, &RequestError{Error: fmt.Errorf("No handler registered for %s", typeName), Message: "Invalid resource type", Code: http.StatusNotFound}
+	}
+	logf(r.Context(), "Found handler for [%v] with [%v]\n", typeName, entry.parameters)
+	params, paramErr := extractPathParameters(argumentPath, entry.parameters)
+	if paramErr != nil {
+		return entry, nil, &RequestError{Error: paramErr, Message: "Invalid resource path", Code: http.StatusNotFound}
+	}
+	return entry, params, nil
+}
+
+func GetResource(r *http.Request) (interface{}, *RequestError) {
+	entry, params, err := resolveResource(r)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err = withPage(r, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, handlerSpan := startSpan(r.Context(), "gowest.handler")
+	handlerSpan.SetAttribute("gowest.type", entry.typeName)
+	resource, err := callHandler(ctx, entry, params, entry.handler)
+	endSpan(handlerSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	return shapeCollection(resource, r, entry)
 }
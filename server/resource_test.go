@@ -56,6 +56,52 @@ var _ = Describe("GET resource handler", func() {
 			Expect(err).To(BeNil())
 		})
 	})
+	Describe("validating a registration", func() {
+		It("should return the parameters of a valid pattern", func() {
+			// Exercise
+			err := Resource(book{}, "/{surname}/{first_name}", getSingleResourceHandler)
+			// Verify
+			Expect(err).To(BeNil())
+			url, _ := URLFor(book{}, map[string]string{"surname": "Gibson", "first_name": "William"})
+			Expect(url).To(Equal("/book/Gibson/William"))
+		})
+		for pattern, message := range map[string]string{
+			"/{title":          "Pattern [/{title] has unbalanced braces",
+			"{title}":          "Pattern [{title}] must start with /",
+			"/title":           "Pattern [/title] has [title], which isn't a parameter e.g. {title}",
+			"/{title}/":        "Pattern [/{title}/] has [], which isn't a parameter e.g. {title}",
+			"/{Title}":         "Parameter [Title] in pattern [/{Title}] may only contain a-z and _",
+			"/{title}/{title}": "Parameter [title] appears more than once in pattern [/{title}/{title}]",
+		} {
+			pattern, message := pattern, message
+			It("should reject the pattern "+pattern, func() {
+				// Exercise
+				err := Resource(book{}, pattern, getSingleResourceHandler)
+				// Verify
+				Expect(err).To(MatchError(message))
+				req := request("http://localhost:8080/book?fmt=json")
+				_, reqErr := GetResource(req)
+				Expect(reqErr.Code).To(Equal(http.StatusNotFound))
+			})
+		}
+		It("should reject a nil handler", func() {
+			// Exercise
+			err := Resource(book{}, "/{title}", nil)
+			// Verify
+			Expect(err).To(MatchError("No GET handler for [book]"))
+		})
+		It("should reject a nil instance", func() {
+			// Exercise
+			err := SingletonResource(nil, getSingleResourceHandler)
+			// Verify
+			Expect(err).To(HaveOccurred())
+		})
+		It("should panic when a resource can't be registered with MustResource", func() {
+			Expect(func() { MustResource(book{}, "/title", getSingleResourceHandler) }).To(Panic())
+			Expect(func() { MustSingletonResource(book{}, nil) }).To(Panic())
+			Expect(func() { MustResource(book{}, "/{title}", getSingleResourceHandler) }).NotTo(Panic())
+		})
+	})
 	Describe("GETting a resource", func() {
 		Context("when requesting a non-existent resource", func() {
 			It("should return a 404 error", func() {
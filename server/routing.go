@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var mountMutex sync.RWMutex
+var mountPrefix string
+
+// SetMountPrefix serves resources below prefix e.g. /api/book rather than /book. Requests for other paths are treated
+// as requests for unknown resources.
+func SetMountPrefix(prefix string) {
+	mountMutex.Lock()
+	defer mountMutex.Unlock()
+
+	mountPrefix = strings.TrimSuffix(prefix, "/")
+	if mountPrefix != "" && !strings.HasPrefix(mountPrefix, "/") {
+		mountPrefix = "/" + mountPrefix
+	}
+}
+
+func getMountPrefix() string {
+	mountMutex.RLock()
+	defer mountMutex.RUnlock()
+
+	return mountPrefix
+}
+
+// mountedPath returns a request path relative to the mount prefix, or false if it isn't below it
+func mountedPath(path string) (string, bool) {
+	prefix := getMountPrefix()
+	if path == prefix {
+		return "/", true
+	}
+	if relative, ok := strings.CutPrefix(path, prefix); ok && strings.HasPrefix(relative, "/") {
+		return relative, true
+	}
+	return "", false
+}
+
+// entryURL fills the pattern of a registered resource with the values of its parameters
+func entryURL(entry mutexEntry, value func(parameter string) (string, bool)) (string, error) {
+	path := getMountPrefix() + "/" + entry.typeName
+	for _, parameter := range entry.parameters {
+		v, ok := value(parameter)
+		if !ok || v == "" {
+			return "", fmt.Errorf("Missing parameter [%s] for [%s]", parameter, entry.typeName)
+		}
+		path += "/" + url.PathEscape(v)
+	}
+	return path, nil
+}
+
+// URLFor builds the URL of a resource from its parameters. The resource is identified by an instance of its type, as
+// passed to Resource, or by its name.
+func URLFor(i interface{}, params map[string]string) (string, error) {
+	var entry mutexEntry
+	name, ok := i.(string)
+	if ok {
+		entry = defaultHandlerMutex.getHandler(name)
+	} else {
+		_, name = getInterfaceTypeName(i)
+		entry = defaultHandlerMutex.entryFor(i)
+	}
+	if entry.handler == nil {
+		return "", fmt.Errorf("No resource registered for [%s]", name)
+	}
+	return entryURL(entry, func(parameter string) (string, bool) {
+		v, ok := params[parameter]
+		return v, ok
+	})
+}
+
+// templateURLFor makes URLFor available to templates, with the parameters as name and value pairs e.g.
+// {{urlFor "author" "name" .Author}}
+func templateURLFor(i interface{}, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("Parameters for [%v] must be name and value pairs", i)
+	}
+	params := make(map[string]string, len(pairs)/2)
+	for idx := 0; idx < len(pairs); idx += 2 {
+		name, ok := pairs[idx].(string)
+		if !ok {
+			return "", fmt.Errorf("Parameter name [%v] must be a string", pairs[idx])
+		}
+		params[name] = fmt.Sprint(pairs[idx+1])
+	}
+	return URLFor(i, params)
+}
+
+// templateFuncs are the functions available to HTML and text templates
+var templateFuncs = map[string]interface{}{"urlFor": templateURLFor, "join": strings.Join}
@@ -0,0 +1,141 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type citation struct {
+	Title string `json:"title"`
+}
+
+var _ = Describe("routing.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+		SetMountPrefix("")
+	})
+	Describe("building a URL", func() {
+		It("should fill in the resource's parameters", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			u, err := URLFor(book{}, map[string]string{"title": "Neuromancer"})
+			// Verify
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u).To(Equal("/book/Neuromancer"))
+		})
+		It("should find the resource by name", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			u, err := URLFor("book", map[string]string{"title": "Neuromancer"})
+			// Verify
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u).To(Equal("/book/Neuromancer"))
+		})
+		It("should escape parameters", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			u, _ := URLFor(book{}, map[string]string{"title": "Pandora's Star/2"})
+			// Verify
+			Expect(u).To(Equal("/book/Pandora%27s%20Star%2F2"))
+		})
+		It("should include the mount prefix", func() {
+			// Setup
+			SetMountPrefix("/api/")
+			SingletonResource(book{}, serverBookHandler)
+			// Exercise
+			u, _ := URLFor(book{}, nil)
+			// Verify
+			Expect(u).To(Equal("/api/book"))
+		})
+		It("should fail when a parameter is missing", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			_, err := URLFor(book{}, map[string]string{"author": "Gibson, William"})
+			// Verify
+			Expect(err).To(MatchError("Missing parameter [title] for [book]"))
+		})
+		It("should fail for an unregistered resource", func() {
+			// Exercise
+			_, err := URLFor(book{}, nil)
+			// Verify
+			Expect(err).To(MatchError("No resource registered for [book]"))
+		})
+	})
+	Describe("routing a URL", func() {
+		for _, title := range []string{"Count Zero", "Pandora's Star/2", "Neuromancer2"} {
+			title := title
+			It("should pass "+title+" to the handler", func() {
+				// Setup
+				var requested string
+				Resource(book{}, "/{title}", func(params PathParameters) (interface{}, *RequestError) {
+					requested, _ = params.Get("title")
+					return book{}, nil
+				})
+				u, _ := URLFor(book{}, map[string]string{"title": title})
+				// Exercise
+				resp := get("http://localhost" + u + "?fmt=json")
+				// Verify
+				Expect(resp.Code).To(Equal(200))
+				Expect(requested).To(Equal(title))
+			})
+		}
+		It("should return 404 when a parameter is missing", func() {
+			// Setup
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book?fmt=json")
+			// Verify
+			Expect(resp.Code).To(Equal(404))
+		})
+	})
+	Describe("using a mount prefix", func() {
+		It("should serve resources below the prefix", func() {
+			// Setup
+			SetMountPrefix("/api")
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/api/book/Neuromancer?fmt=json")
+			// Verify
+			Expect(resp.Code).To(Equal(200))
+		})
+		It("should not serve resources elsewhere", func() {
+			// Setup
+			SetMountPrefix("/api")
+			Resource(book{}, "/{title}", serverBookHandler)
+			// Exercise
+			resp := get("http://localhost/book/Neuromancer?fmt=json")
+			// Verify
+			Expect(resp.Code).To(Equal(404))
+		})
+	})
+	Describe("using URLs in templates", func() {
+		BeforeEach(func() {
+			Resource(book{}, "/{title}", serverBookHandler)
+			SingletonResource(citation{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return citation{"Count Zero"}, nil
+			})
+		})
+		It("should be available in HTML templates", func() {
+			// Exercise
+			resp := get("http://localhost/citation?fmt=html")
+			// Verify
+			Expect(resp.Body.String()).To(Equal(`<a href="/book/Count%20Zero">Count Zero</a>`))
+		})
+		It("should be available in text templates", func() {
+			// Setup
+			Resource(citation{}, "/{title}", func(params PathParameters) (interface{}, *RequestError) {
+				title, _ := params.Get("title")
+				return citation{title}, nil
+			})
+			// Exercise
+			resp := get("http://localhost/citation/Neuromancer?fmt=text")
+			// Verify
+			Expect(resp.Body.String()).To(Equal("Neuromancer: /citation/Neuromancer"))
+		})
+	})
+})
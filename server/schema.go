@@ -0,0 +1,316 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cleggatt/gowest/api"
+)
+
+// Schema is a JSON Schema describing the JSON representation of a type. Validation keywords come from validate tags
+// on struct fields e.g. `validate:"required,min=1,max=100"`, which are also checked against request bodies:
+//
+//   - required: the field must be present and not null
+//   - min, max: limit the value of a number, the length of a string or the number of items in a collection
+//   - len: the exact length of a string or number of items in a collection
+//   - enum: the allowed values, separated by | e.g. enum=draft|published
+//   - email: a string must be an email address
+//   - pattern: a regular expression a string must match. It must come last, as it may contain commas e.g.
+//     `validate:"required,pattern=^[A-Z]"`
+type Schema struct {
+	Dialect              string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+}
+
+// SchemaFor describes the JSON representation of a resource, given an instance of its type
+func SchemaFor(i interface{}) *Schema {
+	t, _ := getInterfaceTypeName(i)
+	return schemaFor(t)
+}
+
+// schemasTypeName is where the schema of each resource is served, below the mount prefix e.g. /schemas/book
+const schemasTypeName = "schemas"
+
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var schemasEntry = mutexEntry{typeName: schemasTypeName, parameters: []string{"type"}, handler: schemaHandler}
+
+func schemaHandler(params PathParameters) (interface{}, *RequestError) {
+	name, _ := params.Get("type")
+	entry := defaultHandlerMutex.getHandler(name)
+	if entry.resourceType == nil {
+		return nil, &RequestError{Error: fmt.Errorf("No resource registered for [%s]", name), Message: "Invalid resource type", Code: http.StatusNotFound}
+	}
+	s := schemaFor(entry.resourceType)
+	s.Dialect = schemaDialect
+	return s, nil
+}
+
+func schemaURL(entry mutexEntry) string {
+	if entry.resourceType == nil {
+		return ""
+	}
+	return getMountPrefix() + "/" + schemasTypeName + "/" + url.PathEscape(entry.typeName)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor describes a type as encoding/json would represent it
+func schemaFor(t reflect.Type) *Schema {
+	return schemaBuilder{visiting: make(map[reflect.Type]bool)}.schema(t)
+}
+
+type schemaBuilder struct {
+	// visiting holds the structs being described, as a recursive type can't be described inline
+	visiting map[reflect.Type]bool
+}
+
+func (b schemaBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Type: "string", Format: "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		if b.visiting[t] {
+			return &Schema{Type: "object"}
+		}
+		b.visiting[t] = true
+		defer delete(b.visiting, t)
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		b.addFields(s, t)
+		return s
+	}
+	// Anything else e.g. an interface could be represented by any JSON value
+	return &Schema{}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isPromoted reports whether a field is an embedded struct without a name of its own, whose fields encoding/json
+// promotes to the containing object
+func isPromoted(f reflect.StructField) bool {
+	return f.Anonymous && indirectType(f.Type).Kind() == reflect.Struct && strings.Split(f.Tag.Get("json"), ",")[0] == ""
+}
+
+func (b schemaBuilder) addFields(s *Schema, t reflect.Type) {
+	for idx := 0; idx < t.NumField(); idx++ {
+		f := t.Field(idx)
+		if isPromoted(f) {
+			b.addFields(s, indirectType(f.Type))
+			continue
+		}
+		if name, ok := jsonFieldName(f); ok {
+			property := b.schema(f.Type)
+			rules := parseValidateTag(f.Tag.Get("validate"))
+			if rules.has("required") {
+				s.Required = append(s.Required, name)
+			}
+			addConstraints(property, rules)
+			s.Properties[name] = property
+		}
+	}
+}
+
+// validationRules are the rules in a validate tag, by name, with their arguments
+type validationRules map[string]string
+
+func parseValidateTag(tag string) validationRules {
+	rules := make(validationRules)
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "pattern=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name != "" {
+			rules[name] = arg
+		}
+	}
+	return rules
+}
+
+func (rules validationRules) has(name string) bool {
+	_, ok := rules[name]
+	return ok
+}
+
+// number returns a numeric argument, or nil if there isn't one
+func (rules validationRules) number(name string) *float64 {
+	if n, err := strconv.ParseFloat(rules[name], 64); err == nil {
+		return &n
+	}
+	return nil
+}
+
+func (rules validationRules) count(name string) *int {
+	if n, err := strconv.Atoi(rules[name]); err == nil && n >= 0 {
+		return &n
+	}
+	return nil
+}
+
+func addConstraints(s *Schema, rules validationRules) {
+	switch s.Type {
+	case "integer", "number":
+		s.Minimum, s.Maximum = rules.number("min"), rules.number("max")
+	case "string":
+		if s.Format != "" {
+			break
+		}
+		s.MinLength, s.MaxLength = rules.count("min"), rules.count("max")
+		if n := rules.count("len"); n != nil {
+			s.MinLength, s.MaxLength = n, n
+		}
+		s.Pattern = rules["pattern"]
+		if rules.has("email") {
+			s.Format = "email"
+		}
+	case "array":
+		s.MinItems, s.MaxItems = rules.count("min"), rules.count("max")
+		if n := rules.count("len"); n != nil {
+			s.MinItems, s.MaxItems = n, n
+		}
+	}
+	for _, value := range rules.enum() {
+		if s.Type == "integer" || s.Type == "number" {
+			s.Enum = append(s.Enum, json.Number(value))
+		} else {
+			s.Enum = append(s.Enum, value)
+		}
+	}
+}
+
+// Violation is a way in which a request doesn't meet the requirements of a resource
+type Violation = api.Violation
+
+var patternMutex sync.Mutex
+var patterns = make(map[string]*regexp.Regexp)
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternMutex.Lock()
+	defer patternMutex.Unlock()
+
+	if re, ok := patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err == nil {
+		patterns[pattern] = re
+	}
+	return re, err
+}
+
+func memberPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// checkTypes checks the types of a value decoded from JSON, with numbers as json.Number, against the schema. The
+// other rules are checked once the value has been decoded into its type, by validateValue.
+func (s *Schema) checkTypes(value interface{}, path string) []Violation {
+	var violations []Violation
+	violate := func(message string) {
+		violations = append(violations, Violation{Field: path, Message: message})
+	}
+	if value == nil {
+		// encoding/json accepts null for any type
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			violate("must be an object")
+			break
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				violations = append(violations, property.checkTypes(object[name], memberPath(path, name))...)
+			} else if s.AdditionalProperties != nil {
+				violations = append(violations, s.AdditionalProperties.checkTypes(object[name], memberPath(path, name))...)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			violate("must be an array")
+			break
+		}
+		for idx, item := range array {
+			violations = append(violations, s.Items.checkTypes(item, fmt.Sprintf("%s[%d]", path, idx))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			violate("must be a string")
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			violate("must be a number")
+			break
+		}
+		if n, err := number.Float64(); err != nil || (s.Type == "integer" && n != math.Trunc(n)) {
+			violate("must be an integer")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			violate("must be true or false")
+		}
+	}
+	return violations
+}
@@ -0,0 +1,127 @@
+package server_test
+
+import (
+	"github.com/cleggatt/gowest/internal/storetest"
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+type submission struct {
+	Title string   `json:"title" validate:"required,min=1,max=20"`
+	Pages int      `json:"pages" validate:"min=1,max=2000"`
+	Tags  []string `json:"tags" validate:"max=2"`
+	ISBN  string   `json:"isbn" validate:"pattern=^[0-9-]+$"`
+}
+
+func intPtr(i int) *int { return &i }
+
+func floatPtr(f float64) *float64 { return &f }
+
+var _ = Describe("schema.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("describing a type", func() {
+		It("should translate validate tags into keywords", func() {
+			// Exercise
+			s := SchemaFor(submission{})
+			// Verify
+			Expect(s.Type).To(Equal("object"))
+			Expect(s.Required).To(Equal([]string{"title"}))
+			Expect(s.Properties["title"]).To(Equal(&Schema{Type: "string", MinLength: intPtr(1), MaxLength: intPtr(20)}))
+			Expect(s.Properties["pages"]).To(Equal(&Schema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(2000)}))
+			Expect(s.Properties["tags"]).To(Equal(&Schema{Type: "array", Items: &Schema{Type: "string"}, MaxItems: intPtr(2)}))
+			Expect(s.Properties["isbn"]).To(Equal(&Schema{Type: "string", Pattern: "^[0-9-]+$"}))
+		})
+	})
+	Describe("validating a request body", func() {
+		It("should pass a valid body to the handler", func() {
+			// Setup
+			store := storetest.Store[submission]{}
+			Resource(submission{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/submission/Idoru?fmt=json", `{"title":"Idoru","pages":292,"isbn":"0-399-14130-8"}`, nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(store["Idoru"]).To(Equal(submission{Title: "Idoru", Pages: 292, ISBN: "0-399-14130-8"}))
+		})
+		It("should return a 422 error listing every violation", func() {
+			// Setup
+			store := storetest.Store[submission]{}
+			Resource(submission{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/submission/Idoru?fmt=json", `{"pages":0,"tags":["a","b",3],"isbn":"ISBN"}`, nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(store).To(BeEmpty())
+			var body struct{ Violations []Violation }
+			Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Violations).To(Equal([]Violation{
+				{Field: "isbn", Message: "must match ^[0-9-]+$"},
+				{Field: "pages", Message: "must be at least 1"},
+				{Field: "tags", Message: "must have at most 2 items"},
+				{Field: "tags[2]", Message: "must be a string"},
+				{Field: "title", Message: "is required"}}))
+		})
+		It("should list the violations in the text fallback", func() {
+			// Setup
+			store := storetest.Store[submission]{}
+			Resource(submission{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/submission/Idoru?fmt=text", `{"title":"Idoru","pages":"many"}`, nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(resp.Body.String()).To(ContainSubstring("pages: must be a number"))
+		})
+		It("should still return a 400 error for malformed JSON", func() {
+			// Setup
+			store := storetest.Store[submission]{}
+			Resource(submission{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/submission/Idoru?fmt=json", `{"title":`, nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+	Describe("serving schemas", func() {
+		It("should serve the schema of a resource at a URL for its type", func() {
+			// Setup
+			store := storetest.Store[submission]{}
+			Resource(submission{}, "/{title}", store.Get, Put(store.Put))
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, httptest.NewRequest("GET", "/schemas/submission?fmt=json", nil))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			var s Schema
+			Expect(json.Unmarshal(resp.Body.Bytes(), &s)).To(Succeed())
+			Expect(s.Dialect).To(Equal("https://json-schema.org/draft/2020-12/schema"))
+			Expect(s.Required).To(Equal([]string{"title"}))
+		})
+		It("should return a 404 error for an unknown type", func() {
+			// Setup
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, httptest.NewRequest("GET", "/schemas/submission?fmt=json", nil))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		})
+		It("should link each resource in the index to its schema", func() {
+			// Setup
+			store := storetest.Store[submission]{}
+			Resource(submission{}, "/{title}", store.Get, Put(store.Put))
+			resp := httptest.NewRecorder()
+			// Exercise
+			MainHandler(resp, httptest.NewRequest("GET", "/?fmt=json", nil))
+			// Verify
+			var index ResourceIndex
+			Expect(json.Unmarshal(resp.Body.Bytes(), &index)).To(Succeed())
+			Expect(index.Resources[0].Schema).To(Equal("/schemas/submission"))
+		})
+	})
+})
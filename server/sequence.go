@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"reflect"
+)
+
+// isSequence reports whether the resource is a lazily produced collection. Handlers may return a receive channel, an
+// iterator such as iter.Seq[T] (i.e. func(yield func(T) bool)), or a function returning the next element and whether
+// there was one, i.e. func() (T, bool).
+func isSequence(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	t := reflect.TypeOf(i)
+	switch t.Kind() {
+	case reflect.Chan:
+		return t.ChanDir()&reflect.RecvDir != 0
+	case reflect.Func:
+		return isPushIterator(t) || isPullIterator(t)
+	}
+	return false
+}
+
+func isPushIterator(t reflect.Type) bool {
+	if t.NumIn() != 1 || t.NumOut() != 0 {
+		return false
+	}
+	yield := t.In(0)
+	return yield.Kind() == reflect.Func && yield.NumIn() == 1 && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
+
+func isPullIterator(t reflect.Type) bool {
+	return t.NumIn() == 0 && t.NumOut() == 2 && t.Out(1).Kind() == reflect.Bool
+}
+
+// forEach calls fn with each element of a sequence, stopping at the first error or when ctx is done
+func forEach(ctx context.Context, i interface{}, fn func(element interface{}) error) error {
+	v := reflect.ValueOf(i)
+	switch {
+	case v.Kind() == reflect.Chan:
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: v}}
+		for {
+			chosen, element, ok := reflect.Select(cases)
+			if chosen == 0 {
+				return ctx.Err()
+			}
+			if !ok {
+				return nil
+			}
+			if err := fn(element.Interface()); err != nil {
+				return err
+			}
+		}
+	case isPushIterator(v.Type()):
+		var err error
+		yield := reflect.MakeFunc(v.Type().In(0), func(args []reflect.Value) []reflect.Value {
+			if err = ctx.Err(); err == nil {
+				err = fn(args[0].Interface())
+			}
+			return []reflect.Value{reflect.ValueOf(err == nil)}
+		})
+		v.Call([]reflect.Value{yield})
+		return err
+	default:
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			results := v.Call(nil)
+			if !results[1].Bool() {
+				return nil
+			}
+			if err := fn(results[0].Interface()); err != nil {
+				return err
+			}
+		}
+	}
+}
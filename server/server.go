@@ -1,34 +1,186 @@
 package server
 
 import (
-	"log"
+	"bytes"
+	"encoding/json"
+	"mime"
 	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cleggatt/gowest/api"
 )
 
 const (
 	StatusInternalServerErrorMessage = "An internal server error has occured."
 )
 
-type RequestError struct {
-	Error   error
-	Message string
-	Code    int
+// RequestError is an error to return to the client, sent as an ErrorRepresentation
+type RequestError = api.RequestError
+
+// ErrorRepresentation is how a RequestError is sent to the client. JSON formats render it directly; other formats use
+// the application's template for it, if there is one, e.g. server.ErrorRepresentation.csv, and plain text otherwise.
+type ErrorRepresentation struct {
+	Message    string      `json:"message"`
+	Code       int         `json:"code"`
+	RequestID  string      `json:"requestId,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
 }
 
 func internalRequestError(e error) *RequestError {
 	return &RequestError{Error: e, Message: StatusInternalServerErrorMessage, Code: http.StatusInternalServerError}
 }
 
+// isJsonFormat reports whether a format's representations are JSON, so errors can be too
+func isJsonFormat(format string) bool {
+	entry, ok := getEncoder(format)
+	if !ok || isRecordFormat(format) {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(entry.mediaType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// writeErrorTemplate renders an error using the application's template for the format, if there is one
+func writeErrorTemplate(w http.ResponseWriter, r *http.Request, format string, e ErrorRepresentation) bool {
+	if _, err := os.Stat(fmtType(e) + "." + format); err != nil {
+		return false
+	}
+	var b bytes.Buffer
+	if err := writeTemplate(r.Context(), e, format, &b); err != nil {
+		return false
+	}
+	w.Header().Set("Content-Type", contentType(format, b.Bytes()))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Code)
+	w.Write(b.Bytes())
+	return true
+}
+
+// representationHeaders describe the representation of a resource. They may have been set before rendering failed, so
+// they're removed from error responses, which mustn't be cached as if they were the resource.
+var representationHeaders = []string{"Age", "Cache-Control", "Content-Encoding", "Content-Length", "ETag", "Last-Modified",
+	"Link", "X-Total-Count"}
+
+func writeError(w http.ResponseWriter, r *http.Request, err *RequestError) {
+	logf(r.Context(), "Returning [%d] response [%s]", err.Code, err.Message)
+	for _, header := range representationHeaders {
+		w.Header().Del(header)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	id := RequestID(r.Context())
+	e := ErrorRepresentation{err.Message, err.Code, id, err.Violations}
+	format := requestFormat(r)
+	if isJsonFormat(format) {
+		if b, jsonErr := json.Marshal(e); jsonErr == nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(err.Code)
+			w.Write(b)
+			return
+		}
+	}
+	if format != "" && writeErrorTemplate(w, r, format, e) {
+		return
+	}
+	message := err.Message
+	for _, violation := range err.Violations {
+		message += "\n" + strings.TrimPrefix(violation.Field+": ", ": ") + violation.Message
+	}
+	if id != "" {
+		message += "\nRequest ID: " + id
+	}
+	http.Error(w, message, err.Code)
+}
+
 func MainHandler(w http.ResponseWriter, r *http.Request) {
 	// TODO Ensure response fmt is valid before proceeding
+	r = withRequestID(w, extractTraceParent(r))
+	ctx, span := startSpan(r.Context(), "gowest.request")
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("gowest.request_id", RequestID(ctx))
+	r = r.WithContext(ctx)
+	r, releaseTimeouts := withHandlerTimeouts(r)
+	defer releaseTimeouts()
+
+	timing := new(requestTiming)
+	tw := &trackingWriter{ResponseWriter: w}
+	err := serveRequest(tw, r, timing)
+	if err != nil && !tw.started {
+		if err.Code == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", strings.Join(allowedMethods(defaultHandlerMutex.getHandler(requestTypeName(r))), ", "))
+		}
+		writeError(w, r, err)
+	}
+	defaultMetrics.observe(metricLabelsFor(r, err), timing)
+	endSpan(span, err)
+	if err != nil && tw.started {
+		// Part of the response has been sent, so all we can do is ensure the client sees it as incomplete
+		logf(r.Context(), "Aborting response after [%d] error [%s]", err.Code, err.Message)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+type requestTiming struct {
+	handler  time.Duration
+	rendered bool
+	render   time.Duration
+}
+
+func serveRequest(w http.ResponseWriter, r *http.Request, timing *requestTiming) *RequestError {
+	entry := defaultHandlerMutex.getHandler(requestTypeName(r))
+	if defaultResponseCache.serve(w, r, entry) {
+		return nil
+	}
+	if !isSafeMethod(r) {
+		return modifyResource(w, r, timing)
+	}
+	if entry.coalesce {
+		return serveCoalesced(w, r, entry, timing)
+	}
+	return serveResource(w, r, entry, timing)
+}
+
+func serveResource(w http.ResponseWriter, r *http.Request, entry mutexEntry, timing *requestTiming) *RequestError {
+	start := time.Now()
 	res, err := GetResource(r)
+	timing.handler = time.Since(start)
 	if err != nil {
-		log.Printf("Returning [%d] response [%s]", err.Code, err.Message)
-		http.Error(w, err.Message, err.Code)
-		return
+		return err
+	}
+
+	timing.rendered = true
+	setCachePolicy(w, entry)
+	out, store := defaultResponseCache.record(w, r, entry)
+	start = time.Now()
+	// MarshallResponse only writes once the representation is complete, so the caller can still report its errors
+	err = MarshallResponse(res, out, r)
+	timing.render = time.Since(start)
+	if err == nil {
+		store()
+	}
+	return err
+}
+
+func modifyResource(w http.ResponseWriter, r *http.Request, timing *requestTiming) *RequestError {
+	start := time.Now()
+	res, err := ModifyResource(r)
+	timing.handler = time.Since(start)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
 	}
 
-	MarshallResponse(res, w, r)
+	timing.rendered = true
+	start = time.Now()
+	err = MarshallResponse(res, w, r)
+	timing.render = time.Since(start)
+	return err
 }
 
 func init() {
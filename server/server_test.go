@@ -33,11 +33,23 @@ var _ = Describe("Main handler", func() {
 			It("should write the correctly formatted response", func() {
 				// Exercise
 				req := request("http://localhost:8080/Missing?fmt=json")
+				req.Header = map[string][]string{"X-Request-Id": {"abc-123"}}
 				resp := httptest.NewRecorder()
 				MainHandler(resp, req)
 				// Verify
 				Expect(resp.Code).To(Equal(404))
-				Expect(resp.Body.String()).To(Equal("Invalid resource type\n"))
+				Expect(resp.Header().Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+				Expect(resp.Body.String()).To(Equal("{\"message\":\"Invalid resource type\",\"code\":404,\"requestId\":\"abc-123\"}"))
+			})
+			It("should fall back to a plain text response", func() {
+				// Exercise
+				req := request("http://localhost:8080/Missing?fmt=html")
+				req.Header = map[string][]string{"X-Request-Id": {"abc-123"}}
+				resp := httptest.NewRecorder()
+				MainHandler(resp, req)
+				// Verify
+				Expect(resp.Code).To(Equal(404))
+				Expect(resp.Body.String()).To(Equal("Invalid resource type\nRequest ID: abc-123\n"))
 			})
 		})
 	})
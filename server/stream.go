@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var streamingMutex sync.RWMutex
+var streamingThreshold int
+
+// SetStreamingThreshold enables streaming of representations larger than n bytes. Smaller representations are
+// buffered as usual, so errors while rendering them still become a clean 500 response. Larger ones are written as
+// they're rendered, so they don't need to be held in memory, but they only have an ETag if the resource supplies one
+// and errors part way through abort the response. A threshold of 0, the default, disables streaming.
+func SetStreamingThreshold(n int) {
+	streamingMutex.Lock()
+	defer streamingMutex.Unlock()
+
+	streamingThreshold = n
+}
+
+func getStreamingThreshold() int {
+	streamingMutex.RLock()
+	defer streamingMutex.RUnlock()
+
+	return streamingThreshold
+}
+
+// preBuffer holds the start of a representation until it grows beyond the limit, at which point the response is
+// started and everything else is written straight through
+type preBuffer struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	v      validators
+	limit  int
+	buff   bytes.Buffer
+	out    io.Writer
+	closer io.Closer
+}
+
+func (pb *preBuffer) Write(p []byte) (int, error) {
+	if pb.out == nil {
+		if pb.buff.Len()+len(p) <= pb.limit {
+			return pb.buff.Write(p)
+		}
+		if err := pb.start(); err != nil {
+			return 0, err
+		}
+	}
+	return pb.out.Write(p)
+}
+
+func (pb *preBuffer) start() error {
+	logf(pb.r.Context(), "Streaming response for [%s]", pb.r.URL.RequestURI())
+	header := pb.w.Header()
+	setRepresentationHeaders(pb.w, pb.r, pb.buff.Bytes())
+	// A compressed variant has its own tag, which a 304 must repeat, so the encoding is chosen first
+	encoding := negotiateEncoding(pb.r)
+	if !shouldCompress(pb.w) {
+		encoding = ""
+	}
+	if encoding != "" && pb.v.etag != "" {
+		pb.v.etag = encodedETag(pb.v.etag, encoding)
+	}
+	if writeValidators(pb.w, pb.r, pb.v) {
+		// The client's copy is current, so the rest of the representation is rendered only to be discarded
+		pb.out = io.Discard
+		pb.buff.Reset()
+		return nil
+	}
+	pb.out = pb.w
+	if encoding != "" {
+		var compressor io.WriteCloser
+		if encoding == "gzip" {
+			compressor = gzip.NewWriter(pb.w)
+		} else {
+			compressor, _ = flate.NewWriter(pb.w, flate.DefaultCompression)
+		}
+		header.Set("Content-Encoding", encoding)
+		pb.out, pb.closer = compressor, compressor
+	}
+	header.Del("Content-Length")
+	_, err := pb.out.Write(pb.buff.Bytes())
+	pb.buff.Reset()
+	return err
+}
+
+func (pb *preBuffer) Flush() {
+	if pb.out == nil {
+		// Flushing is a request to get data to the client, so we stop waiting to see how big the response is
+		if err := pb.start(); err != nil {
+			return
+		}
+	}
+	if f, ok := pb.out.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	http.NewResponseController(pb.w).Flush()
+}
+
+func streamResponse(i interface{}, w http.ResponseWriter, r *http.Request, v validators, threshold int) *RequestError {
+	pb := &preBuffer{w: w, r: r, v: v, limit: threshold}
+	if err := render(i, pb, r); err != nil {
+		if pb.out != nil {
+			logf(r.Context(), "Unable to complete streamed response: %v", err.Error)
+		}
+		return err
+	}
+	if pb.out == nil {
+		return writeBytes(pb.buff.Bytes(), w, r, v)
+	}
+	if pb.closer != nil {
+		if err := pb.closer.Close(); err != nil {
+			logf(r.Context(), "Unable to complete streamed response: %v", err)
+			return internalRequestError(err)
+		}
+	}
+	return nil
+}
+
+// trackingWriter records whether a response has been started, after which errors can no longer be reported
+type trackingWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (tw *trackingWriter) WriteHeader(status int) {
+	tw.started = true
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *trackingWriter) Write(p []byte) (int, error) {
+	tw.started = true
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *trackingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
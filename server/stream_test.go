@@ -0,0 +1,159 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"compress/gzip"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+var books = []book{book{"Neuromancer", "Gibson, William"}, book{"Pandora's Star", "Peter F. Hamilton"}}
+
+const booksJson = "[{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"},{\"title\":\"Pandora's Star\",\"author\":\"Peter F. Hamilton\"}]"
+
+func bookChannel(elements ...interface{}) <-chan interface{} {
+	c := make(chan interface{}, len(elements))
+	for _, element := range elements {
+		c <- element
+	}
+	close(c)
+	return c
+}
+
+func bookSeq() iter.Seq[book] {
+	return func(yield func(book) bool) {
+		for _, b := range books {
+			if !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+func bookPull() func() (book, bool) {
+	idx := 0
+	return func() (book, bool) {
+		if idx == len(books) {
+			return book{}, false
+		}
+		idx++
+		return books[idx-1], true
+	}
+}
+
+var _ = Describe("stream.go", func() {
+	AfterEach(func() {
+		SetStreamingThreshold(0)
+		ClearHandlers()
+	})
+	Describe("rendering a sequence", func() {
+		cases := map[string]func() interface{}{
+			"channel":       func() interface{} { return bookChannel(books[0], books[1]) },
+			"iter.Seq":      func() interface{} { return bookSeq() },
+			"pull iterator": func() interface{} { return bookPull() }}
+		for k, v := range cases {
+			kind, sequence := k, v
+			It("should write a "+kind+" as a JSON array", func() {
+				// Exercise
+				resp := marshall(sequence(), request("http://localhost:8080/book?fmt=json"))
+				// Verify
+				Expect(resp.Body.String()).To(Equal(booksJson))
+			})
+		}
+		It("should write an empty sequence as an empty JSON array", func() {
+			// Exercise
+			resp := marshall(bookChannel(), request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal("[]"))
+		})
+	})
+	Describe("streaming a response", func() {
+		It("should buffer responses below the threshold", func() {
+			// Setup
+			SetStreamingThreshold(1024)
+			// Exercise
+			resp := marshall(bookSeq(), request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal(booksJson))
+			Expect(resp.Header().Get("ETag")).ToNot(Equal(""))
+			Expect(resp.Header().Get("Content-Length")).To(Equal(strconv.Itoa(len(booksJson))))
+		})
+		It("should stream responses above the threshold", func() {
+			// Setup
+			SetStreamingThreshold(10)
+			// Exercise
+			resp := marshall(bookSeq(), request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal(booksJson))
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+			Expect(resp.Header().Get("ETag")).To(Equal(""))
+			Expect(resp.Header().Get("Content-Length")).To(Equal(""))
+		})
+		It("should stream templates", func() {
+			// Setup
+			SetStreamingThreshold(10)
+			// Exercise
+			resp := marshall(books[0], request("http://localhost:8080/book?fmt=html"))
+			// Verify
+			Expect(resp.Body.String()).To(Equal("<html><body>Neuromancer by Gibson, William</body></html>"))
+			Expect(resp.Header().Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+		})
+		It("should compress streamed responses", func() {
+			// Setup
+			SetStreamingThreshold(10)
+			// Exercise
+			resp := marshall(bookSeq(), encodedRequest("http://localhost:8080/book?fmt=json", "gzip"))
+			// Verify
+			Expect(resp.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			reader, err := gzip.NewReader(resp.Body)
+			Expect(err).To(BeNil())
+			body, err := io.ReadAll(reader)
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(Equal(booksJson))
+		})
+		It("should return 304 with the compressed variant's ETag if a streamed response is current", func() {
+			// Setup
+			SetStreamingThreshold(10)
+			req := encodedRequest("http://localhost:8080/book?fmt=json", "gzip")
+			req.Header.Set("If-None-Match", "\"v1-json-gzip\"")
+			// Exercise
+			resp := marshall(versionedBook{"Neuromancer Neuromancer Neuromancer", "v1", time.Time{}}, req)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+			Expect(resp.Header().Get("ETag")).To(Equal("\"v1-json-gzip\""))
+			Expect(resp.Body.Len()).To(Equal(0))
+		})
+		It("should return a 500 error if rendering fails before the threshold", func() {
+			// Setup
+			SetStreamingThreshold(1024)
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return bookChannel(books[0], badJson{}), nil
+			})
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusInternalServerError))
+			Expect(resp.Body.String()).To(ContainSubstring(StatusInternalServerErrorMessage))
+		})
+		It("should abort the response if rendering fails after the threshold", func() {
+			// Setup
+			SetStreamingThreshold(10)
+			SingletonResource(book{}, func(_ PathParameters) (interface{}, *RequestError) {
+				return bookChannel(books[0], badJson{}), nil
+			})
+			// Exercise
+			resp := httptest.NewRecorder()
+			// Verify
+			Expect(func() { MainHandler(resp, request("http://localhost:8080/book?fmt=json")) }).To(PanicWith(http.ErrAbortHandler))
+			Expect(resp.Body.String()).To(HavePrefix("[{\"title\":\"Neuromancer\""))
+		})
+	})
+})
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusServiceUnavailableMessage = "The request took too long to process."
+)
+
+var timeoutMutex sync.RWMutex
+var defaultTimeout time.Duration
+
+// SetDefaultTimeout sets the time allowed for handlers of resources registered without a Timeout option. A zero
+// duration, the default, allows handlers to run indefinitely.
+func SetDefaultTimeout(d time.Duration) {
+	timeoutMutex.Lock()
+	defer timeoutMutex.Unlock()
+
+	defaultTimeout = d
+}
+
+func getDefaultTimeout() time.Duration {
+	timeoutMutex.RLock()
+	defer timeoutMutex.RUnlock()
+
+	return defaultTimeout
+}
+
+// Timeout limits the time the resource's handler may run for. When it expires, the handler's context is cancelled
+// and the client receives a 503 response. The handler itself is not stopped, so it should watch its context. A negative
+// duration disables the default timeout for the resource.
+func Timeout(d time.Duration) ResourceOption {
+	return func(entry *mutexEntry) {
+		entry.timeout = d
+	}
+}
+
+// handlerTimeouts holds the cancel funcs of the timeouts started while handling a request. Handlers may return lazy
+// sequences which watch their context, so the timeouts are only released once the response has been rendered.
+type handlerTimeouts struct {
+	mutex   sync.Mutex
+	cancels []context.CancelFunc
+}
+
+type handlerTimeoutsKey struct{}
+
+// withHandlerTimeouts returns a copy of the request which holds the timeouts of its handlers, and a function to release
+// them once the response is complete
+func withHandlerTimeouts(r *http.Request) (*http.Request, func()) {
+	timeouts := new(handlerTimeouts)
+	release := func() {
+		timeouts.mutex.Lock()
+		defer timeouts.mutex.Unlock()
+		for _, cancel := range timeouts.cancels {
+			cancel()
+		}
+		timeouts.cancels = nil
+	}
+	return r.WithContext(context.WithValue(r.Context(), handlerTimeoutsKey{}, timeouts)), release
+}
+
+// deferTimeout holds cancel until the request's response is complete. It returns false if the context isn't part of a
+// request handled by MainHandler, in which case the caller must cancel it itself.
+func deferTimeout(ctx context.Context, cancel context.CancelFunc) bool {
+	timeouts, ok := ctx.Value(handlerTimeoutsKey{}).(*handlerTimeouts)
+	if !ok {
+		return false
+	}
+	timeouts.mutex.Lock()
+	defer timeouts.mutex.Unlock()
+	timeouts.cancels = append(timeouts.cancels, cancel)
+	return true
+}
+
+type handlerResult struct {
+	resource interface{}
+	err      *RequestError
+}
+
+func callHandler(ctx context.Context, entry mutexEntry, params parameterMap, handler GetHandler) (interface{}, *RequestError) {
+	timeout := entry.timeout
+	if timeout == 0 {
+		timeout = getDefaultTimeout()
+	}
+	if timeout <= 0 {
+		return handler(requestParameters{params, ctx})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	if !deferTimeout(ctx, cancel) {
+		defer cancel()
+	}
+
+	// Buffered, so the handler can finish and be collected after we've given up on it
+	results := make(chan handlerResult, 1)
+	go func() {
+		defer func() {
+			// We're no longer on the server's goroutine, so it can't recover a panic for us
+			if p := recover(); p != nil {
+				logf(ctx, "Handler for [%s] panicked: %v", entry.typeName, p)
+				results <- handlerResult{nil, internalRequestError(fmt.Errorf("Handler panicked: %v", p))}
+			}
+		}()
+		resource, err := handler(requestParameters{params, ctx})
+		results <- handlerResult{resource, err}
+	}()
+
+	select {
+	case result := <-results:
+		return result.resource, result.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			logf(ctx, "Handler for [%s] timed out after %v", entry.typeName, timeout)
+			defaultMetrics.countTimeout(entry.typeName)
+		} else {
+			logf(ctx, "Request for [%s] was cancelled", entry.typeName)
+		}
+		return nil, &RequestError{Error: ctx.Err(), Message: StatusServiceUnavailableMessage, Code: http.StatusServiceUnavailable}
+	}
+}
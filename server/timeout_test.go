@@ -0,0 +1,110 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http/httptest"
+	"time"
+)
+
+func createSlowHandler(cancelled chan bool) GetHandler {
+	return func(params PathParameters) (interface{}, *RequestError) {
+		select {
+		case <-params.Context().Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+		return book{"Neuromancer", "Gibson, William"}, nil
+	}
+}
+
+// contextChannelHandler returns a channel which is filled lazily, stopping when the handler's context is cancelled
+func contextChannelHandler(params PathParameters) (interface{}, *RequestError) {
+	c := make(chan interface{})
+	go func() {
+		defer close(c)
+		for _, b := range books {
+			select {
+			case c <- b:
+			case <-params.Context().Done():
+				return
+			}
+		}
+	}()
+	return (<-chan interface{})(c), nil
+}
+
+func panicHandler(_ PathParameters) (interface{}, *RequestError) {
+	panic("panicHandler")
+}
+
+var _ = Describe("timeout.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+		ClearMetrics()
+		SetDefaultTimeout(0)
+	})
+	Describe("handling a slow request", func() {
+		It("should return a 503 error and cancel the handler", func() {
+			// Setup
+			cancelled := make(chan bool, 1)
+			SingletonResource(book{}, createSlowHandler(cancelled), Timeout(10*time.Millisecond))
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(503))
+			Expect(resp.Body.String()).To(ContainSubstring("\"message\":\"" + StatusServiceUnavailableMessage + "\""))
+			Expect(<-cancelled).To(BeTrue())
+			Expect(metricsBody()).To(ContainSubstring("gowest_timeouts_total{type=\"book\"} 1\n"))
+		})
+		It("should use the default timeout", func() {
+			// Setup
+			cancelled := make(chan bool, 1)
+			SetDefaultTimeout(10 * time.Millisecond)
+			SingletonResource(book{}, createSlowHandler(cancelled))
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(503))
+			Expect(<-cancelled).To(BeTrue())
+		})
+		It("should prefer the resource's timeout to the default", func() {
+			// Setup
+			SetDefaultTimeout(time.Nanosecond)
+			SingletonResource(book{}, getSingleResourceHandler, Timeout(time.Second))
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(200))
+			Expect(resp.Body.String()).To(Equal("{\"title\":\"Neuromancer\",\"author\":\"Gibson, William\"}"))
+		})
+	})
+	Describe("handling a request with a timeout", func() {
+		It("should return a 500 error if the handler panics", func() {
+			// Setup
+			SingletonResource(book{}, panicHandler, Timeout(time.Second))
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(500))
+		})
+		It("should not cancel the handler's context until a sequence has been rendered", func() {
+			// Setup
+			SetDefaultTimeout(time.Second)
+			SingletonResource(book{}, contextChannelHandler)
+			// Exercise
+			resp := httptest.NewRecorder()
+			MainHandler(resp, request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(resp.Code).To(Equal(200))
+			Expect(resp.Body.String()).To(Equal(booksJson))
+		})
+	})
+})
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Span is a single timed operation. Its methods mirror the OpenTelemetry span API, so an OpenTelemetry span can be
+// wrapped with little more than a type conversion.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans. The returned context should carry the new span, so spans started from it become its children.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+var tracerMutex sync.RWMutex
+var defaultTracer Tracer = noopTracer{}
+
+// SetTracer sets the tracer used for all requests. Passing nil disables tracing.
+func SetTracer(t Tracer) {
+	tracerMutex.Lock()
+	defer tracerMutex.Unlock()
+
+	if t == nil {
+		t = noopTracer{}
+	}
+	defaultTracer = t
+}
+
+func startSpan(ctx context.Context, name string) (context.Context, Span) {
+	tracerMutex.RLock()
+	t := defaultTracer
+	tracerMutex.RUnlock()
+	return t.Start(ctx, name)
+}
+
+func endSpan(span Span, err *RequestError) {
+	if err != nil {
+		span.SetAttribute("http.status_code", err.Code)
+		if err.Error != nil {
+			span.RecordError(err.Error)
+		}
+	}
+	span.End()
+}
+
+// TraceParent is the W3C Trace Context identifying the caller's span, as sent in the traceparent header.
+type TraceParent struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+func (tp TraceParent) Sampled() bool {
+	return tp.Flags&0x01 == 0x01
+}
+
+func (tp TraceParent) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(tp.TraceID[:]), hex.EncodeToString(tp.SpanID[:]), tp.Flags)
+}
+
+func decodeHexField(dst []byte, field string) bool {
+	if len(field) != hex.EncodedLen(len(dst)) || strings.ToLower(field) != field {
+		return false
+	}
+	if _, err := hex.Decode(dst, []byte(field)); err != nil {
+		return false
+	}
+	for _, b := range dst {
+		if b != 0 {
+			return true
+		}
+	}
+	// All zero IDs are invalid
+	return false
+}
+
+// ParseTraceParent parses a traceparent header value
+func ParseTraceParent(value string) (TraceParent, error) {
+	var tp TraceParent
+	fields := strings.Split(strings.TrimSpace(value), "-")
+	// Future versions may append fields, but must keep the version 00 layout
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" || (fields[0] == "00" && len(fields) != 4) {
+		return tp, fmt.Errorf("Invalid traceparent [%s]", value)
+	}
+	var flags [1]byte
+	if !decodeHexField(tp.TraceID[:], fields[1]) || !decodeHexField(tp.SpanID[:], fields[2]) || len(fields[3]) != 2 {
+		return tp, fmt.Errorf("Invalid traceparent [%s]", value)
+	}
+	if _, err := hex.Decode(flags[:], []byte(fields[3])); err != nil {
+		return tp, fmt.Errorf("Invalid traceparent [%s]", value)
+	}
+	tp.Flags = flags[0]
+	return tp, nil
+}
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a copy of ctx carrying the caller's trace context
+func ContextWithTraceParent(ctx context.Context, tp TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, tp)
+}
+
+// TraceParentFromContext returns the caller's trace context, as received in the traceparent header. Tracers should
+// use it as the parent of spans that have no local parent.
+func TraceParentFromContext(ctx context.Context) (TraceParent, bool) {
+	tp, ok := ctx.Value(traceParentKey{}).(TraceParent)
+	return tp, ok
+}
+
+func extractTraceParent(r *http.Request) *http.Request {
+	value := r.Header.Get("traceparent")
+	if value == "" {
+		return r
+	}
+	tp, err := ParseTraceParent(value)
+	if err != nil {
+		// The spec requires us to ignore an invalid header and start a new trace
+		return r
+	}
+	return r.WithContext(ContextWithTraceParent(r.Context(), tp))
+}
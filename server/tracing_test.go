@@ -0,0 +1,131 @@
+package server_test
+
+import (
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"context"
+	"net/http/httptest"
+)
+
+type recordedSpan struct {
+	name       string
+	parent     *recordedSpan
+	remote     *TraceParent
+	attributes map[string]interface{}
+	errors     []error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) { s.attributes[key] = value }
+func (s *recordedSpan) RecordError(err error)                      { s.errors = append(s.errors, err) }
+func (s *recordedSpan) End()                                       { s.ended = true }
+
+type spanKey struct{}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, attributes: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent
+	} else if tp, ok := TraceParentFromContext(ctx); ok {
+		span.remote = &tp
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *recordingTracer) names() []string {
+	names := make([]string, len(t.spans))
+	for idx, span := range t.spans {
+		names[idx] = span.name
+	}
+	return names
+}
+
+var _ = Describe("tracing.go", func() {
+	var tracer *recordingTracer
+	BeforeEach(func() {
+		tracer = new(recordingTracer)
+		SetTracer(tracer)
+	})
+	AfterEach(func() {
+		SetTracer(nil)
+		ClearHandlers()
+	})
+	Describe("tracing a request", func() {
+		It("should create a span for each stage", func() {
+			// Setup
+			SingletonResource(book{}, getSingleResourceHandler)
+			// Exercise
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/book?fmt=html"))
+			// Verify
+			Expect(tracer.names()).To(Equal([]string{"gowest.request", "gowest.resolve", "gowest.handler", "gowest.render", "gowest.template"}))
+			for _, span := range tracer.spans {
+				Expect(span.ended).To(BeTrue())
+			}
+			Expect(tracer.spans[1].parent).To(Equal(tracer.spans[0]))
+			Expect(tracer.spans[2].parent).To(Equal(tracer.spans[0]))
+			Expect(tracer.spans[3].parent).To(Equal(tracer.spans[0]))
+			Expect(tracer.spans[4].parent).To(Equal(tracer.spans[3]))
+			Expect(tracer.spans[2].attributes["gowest.type"]).To(Equal("book"))
+		})
+		It("should record errors", func() {
+			// Setup
+			SingletonResource(book{}, errorResourceHandler)
+			// Exercise
+			MainHandler(httptest.NewRecorder(), request("http://localhost:8080/book?fmt=json"))
+			// Verify
+			Expect(tracer.names()).To(Equal([]string{"gowest.request", "gowest.resolve", "gowest.handler"}))
+			Expect(tracer.spans[2].attributes["http.status_code"]).To(Equal(400))
+			Expect(tracer.spans[2].errors).To(HaveLen(1))
+		})
+		It("should use the traceparent header as the remote parent", func() {
+			// Setup
+			SingletonResource(book{}, getSingleResourceHandler)
+			req := request("http://localhost:8080/book?fmt=json")
+			req.Header = map[string][]string{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+			// Exercise
+			MainHandler(httptest.NewRecorder(), req)
+			// Verify
+			Expect(tracer.spans[0].remote).ToNot(BeNil())
+			Expect(tracer.spans[0].remote.String()).To(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+			Expect(tracer.spans[0].remote.Sampled()).To(BeTrue())
+		})
+		It("should ignore an invalid traceparent header", func() {
+			// Setup
+			SingletonResource(book{}, getSingleResourceHandler)
+			req := request("http://localhost:8080/book?fmt=json")
+			req.Header = map[string][]string{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}
+			// Exercise
+			MainHandler(httptest.NewRecorder(), req)
+			// Verify
+			Expect(tracer.spans[0].remote).To(BeNil())
+		})
+	})
+	Describe("parsing a traceparent", func() {
+		It("should reject malformed values", func() {
+			for _, value := range []string{
+				"",
+				"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+				"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+				"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+				"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"} {
+				_, err := ParseTraceParent(value)
+				Expect(err).ToNot(BeNil(), value)
+			}
+		})
+		It("should accept future versions with extra fields", func() {
+			// Exercise
+			tp, err := ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+			// Verify
+			Expect(err).To(BeNil())
+			Expect(tp.Sampled()).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateValue checks a decoded value against the validate tags on its fields, and on those of the structs, slices
+// and maps within it. The JSON it was decoded from, as decoded into an interface{}, tells fields that were missing or
+// null apart from those given their zero value: required is only broken by the former, and the other rules are only
+// checked for the latter.
+func validateValue(v reflect.Value, generic interface{}, path string) []Violation {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var violations []Violation
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			break
+		}
+		members, _ := generic.(map[string]interface{})
+		for idx := 0; idx < v.NumField(); idx++ {
+			f := v.Type().Field(idx)
+			if isPromoted(f) {
+				violations = append(violations, validateValue(v.Field(idx), generic, path)...)
+				continue
+			}
+			if name, ok := jsonFieldName(f); ok {
+				fieldPath := memberPath(path, name)
+				member := members[name]
+				violations = append(violations, checkRules(v.Field(idx), member != nil, parseValidateTag(f.Tag.Get("validate")), fieldPath)...)
+				violations = append(violations, validateValue(v.Field(idx), member, fieldPath)...)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		elements, _ := generic.([]interface{})
+		for idx := 0; idx < v.Len(); idx++ {
+			var element interface{}
+			if idx < len(elements) {
+				element = elements[idx]
+			}
+			violations = append(violations, validateValue(v.Index(idx), element, fmt.Sprintf("%s[%d]", path, idx))...)
+		}
+	case reflect.Map:
+		members, _ := generic.(map[string]interface{})
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			name := fmt.Sprint(key)
+			violations = append(violations, validateValue(v.MapIndex(key), members[name], memberPath(path, name))...)
+		}
+	}
+	return violations
+}
+
+// checkRules checks the value of a single field against the rules in its validate tag. A field is present if the JSON
+// gave it a value other than null.
+func checkRules(v reflect.Value, present bool, rules validationRules, path string) []Violation {
+	if !present {
+		if rules.has("required") {
+			return []Violation{{Field: path, Message: "is required"}}
+		}
+		return nil
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var violations []Violation
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+	switch v.Kind() {
+	case reflect.String:
+		text := v.String()
+		rules.checkLength(utf8.RuneCountInString(text), "must be %s %d characters long", violate)
+		if pattern := rules["pattern"]; pattern != "" {
+			if re, err := compilePattern(pattern); err == nil && !re.MatchString(text) {
+				violate("must match %s", pattern)
+			}
+		}
+		if rules.has("email") {
+			if address, err := mail.ParseAddress(text); err != nil || address.Address != text {
+				violate("must be an email address")
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		rules.checkLength(v.Len(), "must have %s %d items", violate)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rules.checkRange(float64(v.Int()), violate)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rules.checkRange(float64(v.Uint()), violate)
+	case reflect.Float32, reflect.Float64:
+		rules.checkRange(v.Float(), violate)
+	}
+	if values := rules.enum(); values != nil && !contains(values, fmt.Sprint(v.Interface())) {
+		violate("must be one of %s", strings.Join(values, ", "))
+	}
+	return violations
+}
+
+// checkLength checks the len, min and max rules for the length of a string or the number of items in a collection
+func (rules validationRules) checkLength(length int, format string, violate func(string, ...interface{})) {
+	if n := rules.count("len"); n != nil && length != *n {
+		violate(format, "exactly", *n)
+	}
+	if n := rules.count("min"); n != nil && length < *n {
+		violate(format, "at least", *n)
+	}
+	if n := rules.count("max"); n != nil && length > *n {
+		violate(format, "at most", *n)
+	}
+}
+
+func (rules validationRules) checkRange(n float64, violate func(string, ...interface{})) {
+	if min := rules.number("min"); min != nil && n < *min {
+		violate("must be at least %v", *min)
+	}
+	if max := rules.number("max"); max != nil && n > *max {
+		violate("must be at most %v", *max)
+	}
+}
+
+// enum returns the values allowed by an enum rule e.g. enum=draft|published, or nil if there isn't one
+func (rules validationRules) enum() []string {
+	if arg := rules["enum"]; arg != "" {
+		return strings.Split(arg, "|")
+	}
+	return nil
+}
+
+// mergeViolations combines the violations found in the JSON with those found in the value decoded from it, in order
+// of field. The value of a field that isn't of the right type is left as zero, so the rules on it are ignored.
+func mergeViolations(typeViolations []Violation, ruleViolations []Violation) []Violation {
+	violations := append([]Violation(nil), typeViolations...)
+	for _, violation := range ruleViolations {
+		if !hasViolationAt(typeViolations, violation.Field) {
+			violations = append(violations, violation)
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+	return violations
+}
+
+// hasViolationAt reports whether there's a violation for a field or for a value containing it
+func hasViolationAt(violations []Violation, field string) bool {
+	for _, violation := range violations {
+		if violation.Field == "" || violation.Field == field || strings.HasPrefix(field, violation.Field+".") ||
+			strings.HasPrefix(field, violation.Field+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package server_test
+
+import (
+	"github.com/cleggatt/gowest/internal/storetest"
+	. "github.com/cleggatt/gowest/server"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+type contributor struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email" validate:"email"`
+}
+
+type manuscript struct {
+	ISBN         string        `json:"isbn" validate:"len=13"`
+	Status       string        `json:"status" validate:"required,enum=draft|published"`
+	Rating       float64       `json:"rating" validate:"min=0,max=5"`
+	Editor       contributor   `json:"editor"`
+	Contributors []contributor `json:"contributors" validate:"min=1"`
+}
+
+func putManuscript(body string, format string) []Violation {
+	store := storetest.Store[manuscript]{}
+	Resource(manuscript{}, "/{title}", store.Get, Put(store.Put))
+	resp := modifyRequest("PUT", "/manuscript/Neuromancer?fmt="+format, body, nil)
+	Expect(resp.Code).To(Equal(http.StatusUnprocessableEntity))
+	Expect(store).To(BeEmpty())
+	var representation struct{ Violations []Violation }
+	Expect(json.Unmarshal(resp.Body.Bytes(), &representation)).To(Succeed())
+	return representation.Violations
+}
+
+var _ = Describe("validate.go", func() {
+	AfterEach(func() {
+		ClearHandlers()
+	})
+	Describe("validating a decoded request body", func() {
+		It("should accept a valid body", func() {
+			// Setup
+			store := storetest.Store[manuscript]{}
+			Resource(manuscript{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/manuscript/Neuromancer?fmt=json",
+				`{"isbn":"9780441569595","status":"draft","editor":{"name":"Terry"},"contributors":[{"name":"William","email":"wg@example.com"}]}`, nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(store["Neuromancer"].Contributors[0].Email).To(Equal("wg@example.com"))
+		})
+		It("should check lengths, ranges and allowed values", func() {
+			// Exercise
+			violations := putManuscript(`{"isbn":"978","status":"lost","rating":6,"editor":{"name":"Terry"},"contributors":[{"name":"William"}]}`, "json")
+			// Verify
+			Expect(violations).To(Equal([]Violation{
+				{Field: "isbn", Message: "must be exactly 13 characters long"},
+				{Field: "rating", Message: "must be at most 5"},
+				{Field: "status", Message: "must be one of draft, published"}}))
+		})
+		It("should check nested structs and the elements of slices, with the paths of their JSON fields", func() {
+			// Exercise
+			violations := putManuscript(`{"status":"draft","editor":{},"contributors":[{"name":"William"},{"email":"gibson"}]}`, "json")
+			// Verify
+			Expect(violations).To(Equal([]Violation{
+				{Field: "contributors[1].email", Message: "must be an email address"},
+				{Field: "contributors[1].name", Message: "is required"},
+				{Field: "editor.name", Message: "is required"}}))
+		})
+		It("should treat missing and null values as missing", func() {
+			// Exercise
+			violations := putManuscript(`{"status":null,"editor":{"name":"Terry"}}`, "json")
+			// Verify
+			Expect(violations).To(Equal([]Violation{{Field: "status", Message: "is required"}}))
+		})
+		It("should check the rules for zero values that are present", func() {
+			// Exercise
+			violations := putManuscript(`{"isbn":"","status":"","editor":{"name":"Terry"},"contributors":[]}`, "json")
+			// Verify
+			Expect(violations).To(Equal([]Violation{
+				{Field: "contributors", Message: "must have at least 1 items"},
+				{Field: "isbn", Message: "must be exactly 13 characters long"},
+				{Field: "status", Message: "must be one of draft, published"}}))
+		})
+		It("should not check the rules for values of the wrong type", func() {
+			// Exercise
+			violations := putManuscript(`{"status":7,"editor":{"name":"Terry"},"contributors":[{"name":"William"}]}`, "json")
+			// Verify
+			Expect(violations).To(Equal([]Violation{{Field: "status", Message: "must be a string"}}))
+		})
+	})
+	Describe("rendering violations", func() {
+		It("should render them as JSON for formats that are JSON", func() {
+			// Exercise
+			violations := putManuscript(`{"editor":{"name":"Terry"},"contributors":[{"name":"William"}]}`, "hal")
+			// Verify
+			Expect(violations).To(Equal([]Violation{{Field: "status", Message: "is required"}}))
+		})
+		It("should render them with the application's template for other formats", func() {
+			// Setup
+			// The template is named after the server's error type, so it's created here rather than kept as a fixture
+			Expect(os.WriteFile("server.ErrorRepresentation.csv", []byte("field,message{{range .Violations}}\n{{.Field}},{{.Message}}{{end}}\n"), 0644)).To(Succeed())
+			defer os.Remove("server.ErrorRepresentation.csv")
+			store := storetest.Store[manuscript]{}
+			Resource(manuscript{}, "/{title}", store.Get, Put(store.Put))
+			// Exercise
+			resp := modifyRequest("PUT", "/manuscript/Neuromancer?fmt=csv", `{"editor":{},"contributors":[{"name":"William"}]}`, nil)
+			// Verify
+			Expect(resp.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(resp.Header().Get("Content-Type")).To(HavePrefix("text/csv"))
+			Expect(resp.Body.String()).To(Equal("field,message\neditor.name,is required\nstatus,is required\n"))
+		})
+	})
+	Describe("describing the rules", func() {
+		It("should translate them into schema keywords", func() {
+			// Exercise
+			s := SchemaFor(manuscript{})
+			// Verify
+			Expect(s.Properties["isbn"]).To(Equal(&Schema{Type: "string", MinLength: intPtr(13), MaxLength: intPtr(13)}))
+			Expect(s.Properties["status"].Enum).To(Equal([]interface{}{"draft", "published"}))
+			Expect(s.Properties["editor"].Properties["email"].Format).To(Equal("email"))
+			Expect(s.Properties["contributors"].Items.Required).To(Equal([]string{"name"}))
+		})
+	})
+})